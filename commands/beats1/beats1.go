@@ -1,35 +1,50 @@
 package beats1
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
-	"os"
+	"net/http"
 	"strings"
 
 	"github.com/dghubble/oauth1"
 	"github.com/jesselucas/slackcmd/slack"
 )
 
+func init() {
+	slack.Register(slack.CommandInfo{
+		Names:       []string{"/beats1"},
+		Description: "Song currently playing on Beats1",
+		TokenEnv:    "SLACK_KEY_BEATS1",
+		Settings: []slack.Setting{
+			{Key: "consumer_key", Env: "TWITTER_CONSUMER_KEY", Usage: "Twitter app consumer key", Required: true},
+			{Key: "consumer_secret", Env: "TWITTER_CONSUMER_SECRET", Usage: "Twitter app consumer secret", Required: true},
+			{Key: "access_token", Env: "TWITTER_ACCESS_TOKEN", Usage: "Twitter access token", Required: true},
+			{Key: "access_token_secret", Env: "TWITTER_ACCESS_TOKEN_SECRET", Usage: "Twitter access token secret", Required: true},
+		},
+		New: func(deps slack.Deps) slack.Command { return &Command{settings: deps.Settings, client: deps.HTTPClient} },
+	})
+}
+
 type Command struct {
+	settings slack.Settings
+	client   *http.Client
 }
 
 func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, error) {
-	// read credentials from environment variables
-	slackAPIKey := os.Getenv("SLACK_KEY_BEATS1")
-	consumerKey := os.Getenv("TWITTER_CONSUMER_KEY")
-	consumerSecret := os.Getenv("TWITTER_CONSUMER_SECRET")
-	accessToken := os.Getenv("TWITTER_ACCESS_TOKEN")
-	accessTokenSecret := os.Getenv("TWITTER_ACCESS_TOKEN_SECRET")
-	if slackAPIKey == "" || consumerKey == "" || consumerSecret == "" || accessToken == "" || accessTokenSecret == "" {
-		panic("Missing required environment variable")
-	}
+	return cmd.RequestContext(context.Background(), sc)
+}
 
-	// Verify the request is coming from Slack
-	if sc.Token != slackAPIKey {
-		err := errors.New("Unauthorized Slack")
-		return nil, err
+// RequestContext is Request with a context used to cancel the Twitter call
+func (cmd *Command) RequestContext(ctx context.Context, sc *slack.SlashCommand) (*slack.CommandPayload, error) {
+	// read credentials from the beats1 settings
+	consumerKey := cmd.settings.Get("consumer_key")
+	consumerSecret := cmd.settings.Get("consumer_secret")
+	accessToken := cmd.settings.Get("access_token")
+	accessTokenSecret := cmd.settings.Get("access_token_secret")
+	if consumerKey == "" || consumerSecret == "" || accessToken == "" || accessTokenSecret == "" {
+		return nil, slack.ErrNotConfigured
 	}
 
 	// create payload
@@ -46,8 +61,12 @@ func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, erro
 	config := oauth1.NewConfig(consumerKey, consumerSecret)
 	token := oauth1.NewToken(accessToken, accessTokenSecret)
 
-	// httpClient will automatically authorize http.Request's
+	// httpClient will automatically authorize http.Request's and send them
+	// through the shared client so they're measured and correlated
 	httpClient := config.Client(token)
+	if t, ok := httpClient.Transport.(*oauth1.Transport); ok && cmd.client != nil {
+		t.Base = cmd.client.Transport
+	}
 
 	url := fmt.Sprintf(
 		"https://api.twitter.com/1.1/statuses/user_timeline.json?screen_name=%v&count=%v&trim_user=%v",
@@ -55,9 +74,18 @@ func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, erro
 		"1",
 		"true",
 	)
-	res, err := httpClient.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
 	defer res.Body.Close()
-	body, _ := ioutil.ReadAll(res.Body)
+
+	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -1,18 +1,17 @@
 package calendar
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/jesselucas/slackcmd/slack"
-	"golang.org/x/net/context"
 	"golang.org/x/oauth2"
 	"google.golang.org/api/calendar/v3"
 )
@@ -34,29 +33,78 @@ func (t *easyTime) endOfDay() time.Time {
 	return t.beginningOfDay().Add(24*time.Hour - time.Nanosecond)
 }
 
+func init() {
+	slack.Register(slack.CommandInfo{
+		Names:       []string{"/conference"},
+		Description: "Schedule for FG Conference room",
+		TokenEnv:    "SLACK_KEY_CALENDAR",
+		Section:     "calendar",
+		Settings: []slack.Setting{
+			{Key: "client_id", Env: "GOOGLE_CALENDAR_CLIENT_ID", Usage: "Google OAuth client ID", Required: true},
+			{Key: "client_secret", Env: "GOOGLE_CALENDAR_CLIENT_SECRET", Usage: "Google OAuth client secret", Required: true},
+			{Key: "refresh_token", Env: "GOOGLE_CALENDAR_REFRESH_TOKEN", Usage: "Google OAuth refresh token", Required: true},
+			{Key: "calendar_id", Env: "SLACK_CALENDAR_ID", Usage: "ID of the conference room calendar", Required: true},
+		},
+		Flags: func(fs *slack.FlagSet) {
+			slack.SetDateFlag(fs, "date", "d", "Show the schedule for a date instead of a weekday", "")
+		},
+		New:   func(deps slack.Deps) slack.Command { return &Command{settings: deps.Settings, client: deps.HTTPClient} },
+		Check: check,
+	})
+}
+
+// check refreshes the Google access token
+func check(ctx context.Context, deps slack.Deps) error {
+	token, err := getAccessToken(ctx, deps.HTTPClient, deps.Settings.Get("client_id"), deps.Settings.Get("client_secret"), deps.Settings.Get("refresh_token"))
+	if err != nil {
+		return err
+	}
+	if token.AccessToken == "" {
+		return errors.New("Google did not refresh the calendar access token")
+	}
+	return nil
+}
+
 type Command struct {
+	settings slack.Settings
+	client   *http.Client // calls Google, defaults to http.DefaultClient
 }
 
 func formatForSlack(s string) string {
 	return fmt.Sprintf("```\n%v```", s)
 }
 
-func getAccessToken(clientID string, clientSecret string, refreshToken string) oauth2.Token {
+func getAccessToken(ctx context.Context, client *http.Client, clientID string, clientSecret string, refreshToken string) (oauth2.Token, error) {
+	var token oauth2.Token
+
 	formValues := url.Values{
 		"client_id":     {clientID},
 		"client_secret": {clientSecret},
 		"refresh_token": {refreshToken},
 		"grant_type":    {"refresh_token"},
 	}
-	resp, _ := http.PostForm("https://accounts.google.com/o/oauth2/token", formValues)
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://accounts.google.com/o/oauth2/token", strings.NewReader(formValues.Encode()))
+	if err != nil {
+		return token, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return token, err
+	}
 	defer resp.Body.Close()
 
-	bodyData, _ := ioutil.ReadAll(resp.Body)
-	// body := string(bodyData)
+	bodyData, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return token, err
+	}
 
-	var token oauth2.Token
-	json.Unmarshal(bodyData, &token)
-	return token
+	err = json.Unmarshal(bodyData, &token)
+	return token, err
 }
 
 func getNextWeekdayOccurance(paramString string) time.Time {
@@ -74,23 +122,19 @@ func getNextWeekdayOccurance(paramString string) time.Time {
 }
 
 func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, error) {
+	return cmd.RequestContext(context.Background(), sc)
+}
 
-	// Read the appropriate environment variables
-	slackAPIKey := os.Getenv("SLACK_KEY_CALENDAR")
-	clientID := os.Getenv("GOOGLE_CALENDAR_CLIENT_ID")
-	clientSecret := os.Getenv("GOOGLE_CALENDAR_CLIENT_SECRET")
-	refreshToken := os.Getenv("GOOGLE_CALENDAR_REFRESH_TOKEN")
-	calendarID := os.Getenv("SLACK_CALENDAR_ID")
-
-	if clientID == "" || clientSecret == "" || refreshToken == "" {
-		err := errors.New("Server Configuration Error")
-		return nil, err
-	}
+// RequestContext is Request with a context used to cancel calls to Google
+func (cmd *Command) RequestContext(ctx context.Context, sc *slack.SlashCommand) (*slack.CommandPayload, error) {
+	// Read the calendar settings
+	clientID := cmd.settings.Get("client_id")
+	clientSecret := cmd.settings.Get("client_secret")
+	refreshToken := cmd.settings.Get("refresh_token")
+	calendarID := cmd.settings.Get("calendar_id")
 
-	// Verify the request is coming from Slack
-	if sc.Token != slackAPIKey {
-		err := errors.New("Unauthorized Slack")
-		return nil, err
+	if clientID == "" || clientSecret == "" || refreshToken == "" || calendarID == "" {
+		return nil, slack.ErrNotConfigured
 	}
 
 	// Create initial payload
@@ -114,36 +158,46 @@ func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, erro
 	}
 
 	// Create a client using our config, context, and access token
-	context := context.Background()
-	token := getAccessToken(clientID, clientSecret, refreshToken)
-	client := config.Client(context, &token)
+	token, err := getAccessToken(ctx, cmd.client, clientID, clientSecret, refreshToken)
+	if err != nil {
+		return nil, slack.WrapError(slack.Upstream, fmt.Errorf("refreshing calendar access token: %v", err))
+	}
+	// oauth2 sends the calendar requests with the client in ctx
+	if cmd.client != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, cmd.client)
+	}
+	client := config.Client(ctx, &token)
 
 	// Get a calendar service
 	service, err := calendar.New(client)
 	if err != nil {
-		err := errors.New("Unable to retrieve calendar client")
-		return nil, err
+		return nil, fmt.Errorf("creating calendar client: %v", err)
 	}
 
 	// Setup the parameters for our calendar request.
 	// We want to request all events from the specified
 	// date until the end of that day
 	requestDate := easyTime{getNextWeekdayOccurance(sc.Text)}
+	if sc.Flags.Lookup("date").IsSet() {
+		requestDate = easyTime{sc.Flags.Date("date")}
+	}
 	timeMin := requestDate.Format(time.RFC3339)
 	timeMax := requestDate.endOfDay().Format(time.RFC3339)
 
 	// We want to request this information for a specific calendar ID
-	events, err := service.Events.List(calendarID).ShowDeleted(false).SingleEvents(true).TimeMin(timeMin).TimeMax(timeMax).MaxResults(50).OrderBy("startTime").Do()
+	events, err := service.Events.List(calendarID).ShowDeleted(false).SingleEvents(true).TimeMin(timeMin).TimeMax(timeMax).MaxResults(50).OrderBy("startTime").Context(ctx).Do()
 	if err != nil {
-		err := errors.New("Unable to retrieve calendar events.")
-		return nil, err
+		return nil, slack.WrapError(slack.Upstream, fmt.Errorf("listing calendar events: %v", err))
 	}
 
-	// Loop through the events received, and append them to the payload text
-	payloadText := "Conference Room Schedule: " + requestDate.Format("Mon. Jan 2, 2006") + "\n"
+	// Loop through the events received, and append them to the payload text.
+	// The same schedule is laid out as blocks with the text as fallback.
+	title := "Conference Room Schedule: " + requestDate.Format("Mon. Jan 2, 2006")
+	payloadText := title + "\n"
+	blocks := slack.NewBlocks().Header(title)
 	format := "03:04PM"
 	if len(events.Items) > 0 {
-		for _, i := range events.Items {
+		for n, i := range events.Items {
 			// If the DateTime is an empty string the Event is an all-day Event.
 			// So only Date is available.
 			var timeString string
@@ -160,11 +214,20 @@ func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, erro
 			}
 
 			payloadText += fmt.Sprintf("• [%v] <%v|%v>\n", timeString, i.HtmlLink, i.Summary)
+
+			// leave room for the header and a count of the events not shown
+			if n < slack.MaxMessageBlocks-2 {
+				blocks.Section(fmt.Sprintf("`%v` <%v|%v>", timeString, i.HtmlLink, i.Summary))
+			} else if n == slack.MaxMessageBlocks-2 {
+				blocks.Context(fmt.Sprintf("%v more events", len(events.Items)-n))
+			}
 		}
 	} else {
 		payloadText += "• No events scheduled.\n"
+		blocks.Section("No events scheduled.")
 	}
 
 	payload.Text = formatForSlack(payloadText)
+	payload.Blocks = blocks.Blocks()
 	return payload, nil
 }
@@ -1,11 +1,13 @@
 package qotd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
-	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -15,21 +17,46 @@ import (
 	"github.com/jesselucas/validator"
 )
 
-// Command struct is only defined to add the Request method
+func init() {
+	slack.Register(slack.CommandInfo{
+		Names:       []string{"/qotd"},
+		Description: "Sends the Question of the Day",
+		TokenEnv:    "SLACK_KEY_QOTD",
+		Settings:    settings,
+		New:         func(deps slack.Deps) slack.Command { return &Command{deps: deps} },
+		Check: func(ctx context.Context, deps slack.Deps) error {
+			_, err := todaysQuestion(ctx, deps)
+			return err
+		},
+	})
+
+	slack.HandleAction("qotd", shareAction, share)
+	slack.HandleEvent("qotd", slack.AppMention, mention)
+}
+
+// settings of the qotd config section
+var settings = []slack.Setting{
+	{Key: "url", Env: "QOTD_URL", Usage: "URL of a YAML list of questions", Required: true},
+}
+
+// action_id of the button that shares the question with the channel
+const shareAction = "qotd_share"
+
+// questionsTTL is how long the fetched questions are cached
+const questionsTTL = time.Hour
+
+// Command caches the questions in its store
 type Command struct {
+	deps slack.Deps
 }
 
 // Request is used to send back to slackcmd
 func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, error) {
-	// read credentials from environment variables
-	slackAPIKey := os.Getenv("SLACK_KEY_QOTD")
-
-	// Verify the request is coming from Slack
-	if sc.Token != slackAPIKey {
-		err := errors.New("Unauthorized Slack")
-		return nil, err
-	}
+	return cmd.RequestContext(context.Background(), sc)
+}
 
+// RequestContext is Request with a context used to cancel fetching the questions
+func (cmd *Command) RequestContext(ctx context.Context, sc *slack.SlashCommand) (*slack.CommandPayload, error) {
 	// create payload
 	cp := &slack.CommandPayload{
 		Channel:       fmt.Sprintf("@%v", sc.UserName),
@@ -39,32 +66,129 @@ func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, erro
 		SendPayload:   false,
 	}
 
-	// url for QOTD YAML
-	url := os.Getenv("QOTD_URL")
-	if !validator.IsURL(url) {
-		return nil, errors.New("QOTD_URL is not a valid URL")
+	question, err := todaysQuestion(ctx, cmd.deps)
+	if err != nil {
+		return nil, err
 	}
 
-	res, err := http.Get(url)
-	defer res.Body.Close()
-	body, _ := ioutil.ReadAll(res.Body)
+	cp.Text = "QOTD: " + question
+	cp.Blocks = slack.NewBlocks().
+		SectionWithAccessory(cp.Text, slack.NewButton(shareAction, "Share with channel", question)).
+		Blocks()
+
+	return cp, nil
+}
+
+// mention answers "@bot qotd" with the question of the day
+func mention(ctx context.Context, req *slack.EventRequest) (*slack.CommandPayload, error) {
+	if !strings.Contains(strings.ToLower(req.Event.Text), "qotd") {
+		return nil, nil
+	}
+
+	question, err := todaysQuestion(ctx, slack.DepsFrom(ctx))
 	if err != nil {
 		return nil, err
 	}
 
+	return &slack.CommandPayload{
+		Username: "QOTD",
+		Emoji:    ":question:",
+		Text:     "QOTD: " + question,
+	}, nil
+}
+
+// todaysQuestion fetches the questions from the url setting, or the store
+// when they are cached, and picks today's. The store may be nil.
+func todaysQuestion(ctx context.Context, deps slack.Deps) (string, error) {
+	body, err := fetchQuestions(ctx, deps)
+	if err != nil {
+		return "", err
+	}
+
 	// Unmarshal YAML
 	var questions []string
 	err = yaml.Unmarshal(body, &questions)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	if len(questions) == 0 {
+		return "", slack.WrapError(slack.Misconfigured, errors.New("qotd url has no questions"))
 	}
 
 	// Get todays index
 	index := getTodaysIndex(uint(len(questions)))
 
-	cp.Text = "QOTD: " + questions[index]
+	return questions[index], nil
+}
+
+// fetchQuestions returns the YAML at the url setting. The client defaults
+// to http.DefaultClient.
+func fetchQuestions(ctx context.Context, deps slack.Deps) ([]byte, error) {
+	store, url := deps.Store, deps.Settings.Get("url")
+	if store != nil {
+		body, err := store.Get(ctx, "questions")
+		if err == nil {
+			return body, nil
+		}
+	}
 
-	return cp, nil
+	if !validator.IsURL(url) {
+		return nil, slack.WrapError(slack.Misconfigured, errors.New("qotd url is not a valid URL"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := deps.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, slack.WrapError(slack.Upstream, fmt.Errorf("qotd url returned %v", res.Status))
+	}
+
+	if store != nil {
+		err := store.Set(ctx, "questions", body, questionsTTL)
+		if err != nil {
+			logger := deps.Logger
+			if logger == nil {
+				logger = slog.Default()
+			}
+			logger.WarnContext(ctx, "caching questions failed", "err", err)
+		}
+	}
+
+	return body, nil
+}
+
+// share posts the question from the share button to the channel
+func share(ctx context.Context, in *slack.Interaction, a *slack.Action) (*slack.CommandPayload, error) {
+	if a.Value == "" {
+		return nil, errors.New("share button has no question")
+	}
+
+	text := "QOTD: " + a.Value
+	return &slack.CommandPayload{
+		Text:         text,
+		ResponseType: slack.ResponseInChannel,
+		Blocks: slack.NewBlocks().
+			Section(text).
+			Context(fmt.Sprintf("Shared by <@%v>", in.User.Id)).
+			Blocks(),
+	}, nil
 }
 
 // getTodaysIndex subjects the startDate by today's date to get the
@@ -76,7 +200,6 @@ func getTodaysIndex(length uint) uint {
 	// Find out day offset of today from the startDate
 	offsetDuration := simpletime.NewSimpleTime(startDate).Since(time.Now())
 	offsetDays := offsetDuration.Days()
-	fmt.Println(offsetDays)
 
 	return uint(offsetDays) % length
 }
@@ -12,8 +12,14 @@ var cmd *Command
 var sc *slack.SlashCommand
 
 func init() {
+	// setup environment variables
+	// Make sure you have QOTD_URL set as an environment variable
+	// os.Setenv("QOTD_URL", "http://urltoquestions.yaml")
+	os.Setenv("SLACK_KEY_QOTD", "Js7gTRur9cWBjXnWdYfm2XXy")
+
 	// Create Command
-	cmd = new(Command)
+	resolved, _ := slack.ResolveSettings(settings, nil)
+	cmd = &Command{deps: slack.Deps{Settings: resolved}}
 
 	sc = &slack.SlashCommand{
 		Token:       "Js7gTRur9cWBjXnWdYfm2XXy",
@@ -27,11 +33,6 @@ func init() {
 		Text:        "",
 		Hook:        "https://hooks.slack.com/commands/1234/5678",
 	}
-
-	// setup environment variables
-	// Make sure you have QOTD_URL set as an environment variable
-	// os.Setenv("QOTD_URL", "http://urltoquestions.yaml")
-	os.Setenv("SLACK_KEY_QOTD", "Js7gTRur9cWBjXnWdYfm2XXy")
 }
 
 func TestRequest(t *testing.T) {
@@ -1,54 +1,169 @@
 package trello
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"regexp"
 	"strings"
 
 	"github.com/jesselucas/slackcmd/slack"
 )
 
+func init() {
+	slack.Register(slack.CommandInfo{
+		Names:       []string{"/fg"},
+		Description: "FG Trello access",
+		TokenEnv:    "SLACK_KEY_TRELLO",
+		Section:     section,
+		Settings: []slack.Setting{
+			{Key: "key", Env: "TRELLO_KEY", Usage: "Trello API key", Required: true},
+			{Key: "token", Env: "TRELLO_TOKEN", Usage: "Trello API token", Required: true},
+			{Key: "org", Env: "TRELLO_ORG", Default: "forestgiant", Usage: "Trello organization whose boards are shown"},
+		},
+		New: func(deps slack.Deps) slack.Command {
+			cmd := NewCommand(deps.Settings, deps.HTTPClient)
+			cmd.logger = deps.Logger
+			return cmd
+		},
+		Check: check,
+	})
+
+	slack.HandleCallback(section, addCallback, createCard)
+	slack.HandleEvent(section, slack.LinkShared, unfurl)
+}
+
+// section of the config holding the Trello settings
+const section = "trello"
+
+// callback_id of the add card modal and the block_ids of its inputs. Every
+// input uses inputAction as its action_id.
+const (
+	addCallback = "trello_add"
+	boardInput  = "board"
+	listInput   = "list"
+	titleInput  = "title"
+	descInput   = "desc"
+	inputAction = "value"
+)
+
+// Command is the /fg subcommand tree. The Subcommand methods make it a
+// slack.Command.
 type Command struct {
+	*slack.Subcommand
+	settings slack.Settings
+	client   *http.Client
+	logger   *slog.Logger
 }
 
-func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, error) {
-	slackAPIKey := os.Getenv("SLACK_KEY_TRELLO")
-	trelloKey := os.Getenv("TRELLO_KEY")
-	trelloToken := os.Getenv("TRELLO_TOKEN")
-	trelloOrg := "forestgiant"
-	if slackAPIKey == "" || trelloKey == "" || trelloToken == "" {
-		panic("Missing required environment variable")
+// log returns the logger the server passed the command or slog's default
+func (cmd *Command) log() *slog.Logger {
+	if cmd.logger == nil {
+		return slog.Default()
 	}
+	return cmd.logger
+}
 
-	// Verify the request is coming from Slack
-	if sc.Token != slackAPIKey {
-		err := errors.New("Unauthorized Slack")
-		return nil, err
+// NewCommand returns /fg with its subcommands. client calls Trello and
+// defaults to http.DefaultClient.
+func NewCommand(settings slack.Settings, client *http.Client) *Command {
+	cmd := &Command{settings: settings, client: client}
+	cmd.Subcommand = &slack.Subcommand{
+		Name:  "/fg",
+		Usage: "FG Trello access",
+		Args: []slack.Arg{
+			{Name: "board", Usage: `Board to list, quote names with spaces Ex. "Design Team"`},
+			{Name: "list", Usage: "List in the board to show cards for"},
+		},
+		Handler: cmd.browse,
+		Subcommands: []*slack.Subcommand{
+			{
+				Name:  "search",
+				Usage: "Search cards on " + settings.Get("org") + " boards",
+				Args: []slack.Arg{
+					{Name: "query", Usage: "Words to search for", Required: true, Variadic: true},
+				},
+				Flags: func(fs *slack.FlagSet) {
+					slack.SetIntFlag(fs, "limit", "l", "Maximum number of cards", 10)
+				},
+				Handler: cmd.search,
+			},
+			{
+				Name:  "add",
+				Usage: "Create a card with a form",
+				Args: []slack.Arg{
+					{Name: "board", Usage: "Board to fill in"},
+					{Name: "list", Usage: "List to fill in"},
+				},
+				Handler: cmd.add,
+			},
+		},
 	}
 
-	// create payload
-	cp := &slack.CommandPayload{
+	return cmd
+}
+
+// credentials returns the Trello key and token settings
+func credentials(settings slack.Settings) (key string, token string, err error) {
+	key = settings.Get("key")
+	token = settings.Get("token")
+	if key == "" || token == "" {
+		return "", "", slack.ErrNotConfigured
+	}
+
+	return key, token, nil
+}
+
+// check fetches the Trello organization with the key and token
+func check(ctx context.Context, deps slack.Deps) error {
+	trelloKey, trelloToken, err := credentials(deps.Settings)
+	if err != nil {
+		return err
+	}
+
+	orgURL := fmt.Sprintf(
+		"https://api.trello.com/1/organizations/%v?fields=name&key=%v&token=%v",
+		deps.Settings.Get("org"),
+		trelloKey,
+		trelloToken,
+	)
+
+	var org struct {
+		Name string `json:"name"`
+	}
+	return getJSON(ctx, deps.HTTPClient, orgURL, &org)
+}
+
+func newPayload(sc *slack.SlashCommand) *slack.CommandPayload {
+	return &slack.CommandPayload{
 		Channel:       fmt.Sprintf("@%v", sc.UserName),
 		Username:      "FG Bot",
 		Emoji:         ":fgdot:",
 		SlashResponse: true,
 		SendPayload:   false,
 	}
+}
+
+// browse lists boards, the lists of a board or the cards of a list
+func (cmd *Command) browse(ctx context.Context, sc *slack.SlashCommand, args []string) (*slack.CommandPayload, error) {
+	trelloKey, trelloToken, err := credentials(cmd.settings)
+	if err != nil {
+		return nil, err
+	}
 
-	c := strings.Fields(sc.Text)
+	// create payload
+	cp := newPayload(sc)
 
-	fmt.Println("c strings?", c)
+	c := make([]string, len(args))
+	copy(c, args)
 
-	// replace all underscores "_" with spaces " " in commands
-	commands := make([]string, len(c))
-	copy(commands, c)
+	cmd.log().DebugContext(ctx, "browsing boards", "args", c)
 
+	// underscores "_" still stand for spaces " " in names
 	for i := 0; i < len(c); i++ {
 		c[i] = strings.Replace(c[i], "_", " ", -1)
 	}
@@ -56,22 +171,18 @@ func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, erro
 	// construct url for Trello
 	url := fmt.Sprintf(
 		"https://api.trello.com/1/organizations/%v/boards/?fields=name&filter=open&key=%v&token=%v",
-		trelloOrg,
+		cmd.settings.Get("org"),
 		trelloKey,
 		trelloToken,
 	)
 
-	res, err := http.Get(url)
-	defer res.Body.Close()
-	body, _ := ioutil.ReadAll(res.Body)
+	// found boards return if only sent one command
+	var boards []board
+	err = getJSON(ctx, cmd.client, url, &boards)
 	if err != nil {
 		return nil, err
 	}
 
-	// found boards return if only sent one command
-	var boards []board
-	json.Unmarshal(body, &boards)
-
 	var responseString string
 
 	// if the command is blank return possible commands(boards)
@@ -82,7 +193,7 @@ func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, erro
 			responseString += fmt.Sprint(board)
 		}
 
-		cp.Text = formatForSlack(c, responseString)
+		cp.Text = formatForSlack(nil, responseString)
 
 		return cp, nil
 	}
@@ -114,28 +225,21 @@ func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, erro
 		trelloToken,
 	)
 
-	// fmt.Println("url: ", url)
-
-	res, err = http.Get(url)
-	body, _ = ioutil.ReadAll(res.Body)
+	var lists []list
+	err = getJSON(ctx, cmd.client, url, &lists)
 	if err != nil {
 		return nil, err
 	}
 
-	var lists []list
-	json.Unmarshal(body, &lists)
-
 	// if the second command is black return lists
 	if len(c) == 1 {
 
-		fmt.Println("check command length", c)
-
 		// iterate over boards and create string to send
 		for _, list := range lists {
 			responseString += fmt.Sprint(list)
 		}
 
-		cp.Text = formatForSlack(commands, responseString)
+		cp.Text = formatForSlack([]string{foundBoard.Name}, responseString)
 
 		return cp, nil
 	}
@@ -153,7 +257,7 @@ func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, erro
 
 	// if nothing matches return
 	if foundList.Name == "" {
-		cp.Text = "invalid board name"
+		cp.Text = "invalid list name"
 		return cp, nil
 	}
 
@@ -164,14 +268,11 @@ func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, erro
 		trelloKey,
 		trelloToken,
 	)
-	res, err = http.Get(url)
-	body, _ = ioutil.ReadAll(res.Body)
+	err = getJSON(ctx, cmd.client, url, &foundList)
 	if err != nil {
 		return nil, err
 	}
 
-	json.Unmarshal(body, &foundList)
-
 	// iterate over boards and create string to send
 	for _, card := range foundList.Cards {
 		// check if they are urls
@@ -179,20 +280,328 @@ func (cmd *Command) Request(sc *slack.SlashCommand) (*slack.CommandPayload, erro
 		responseString += fmt.Sprint(card)
 	}
 
+	path := []string{foundBoard.Name, foundList.Name}
+	if responseString == "" {
+		cp.Text = formatForSlack(path, "This list has no cards to display.")
+		return cp, nil
+	}
+
+	cp.Text = formatForSlack(path, responseString)
+
+	return cp, nil
+
+}
+
+// search finds cards matching the query on the organization's boards
+func (cmd *Command) search(ctx context.Context, sc *slack.SlashCommand, args []string) (*slack.CommandPayload, error) {
+	trelloKey, trelloToken, err := credentials(cmd.settings)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := newPayload(sc)
+	commands := append([]string{"search"}, args...)
+
+	// limit the search to the organization's boards
+	boardsURL := fmt.Sprintf(
+		"https://api.trello.com/1/organizations/%v/boards/?fields=name&filter=open&key=%v&token=%v",
+		cmd.settings.Get("org"),
+		trelloKey,
+		trelloToken,
+	)
+
+	var boards []board
+	err = getJSON(ctx, cmd.client, boardsURL, &boards)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := sc.Flags.Int("limit")
+	if limit <= 0 {
+		limit = 10
+	}
+
+	var boardIDs []string
+	for _, b := range boards {
+		boardIDs = append(boardIDs, b.Id)
+	}
+
+	searchURL := fmt.Sprintf(
+		"https://api.trello.com/1/search?query=%v&idBoards=%v&modelTypes=cards&card_fields=name&cards_limit=%v&partial=true&key=%v&token=%v",
+		url.QueryEscape(strings.Join(args, " ")),
+		strings.Join(boardIDs, ","),
+		limit,
+		trelloKey,
+		trelloToken,
+	)
+
+	var results struct {
+		Cards []card
+	}
+	err = getJSON(ctx, cmd.client, searchURL, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	var responseString string
+	for _, card := range results.Cards {
+		card.URL = IsURL(card.Name)
+		responseString += fmt.Sprint(card)
+	}
+
 	if responseString == "" {
-		cp.Text = formatForSlack(commands, "This list has no cards to display.")
+		cp.Text = formatForSlack(commands, "No cards found.")
 		return cp, nil
 	}
 
 	cp.Text = formatForSlack(commands, responseString)
 
 	return cp, nil
+}
+
+// add opens a modal to create a card, filled in with the board and list
+func (cmd *Command) add(ctx context.Context, sc *slack.SlashCommand, args []string) (*slack.CommandPayload, error) {
+	var boardName, listName string
+	if len(args) > 0 {
+		boardName = args[0]
+	}
+	if len(args) > 1 {
+		listName = args[1]
+	}
+
+	blocks := slack.NewBlocks().
+		Input(boardInput, "Board", &slack.PlainTextInputElement{ActionId: inputAction, InitialValue: boardName}, false).
+		Input(listInput, "List", &slack.PlainTextInputElement{ActionId: inputAction, InitialValue: listName}, false).
+		Input(titleInput, "Title", &slack.PlainTextInputElement{ActionId: inputAction}, false).
+		Input(descInput, "Description", &slack.PlainTextInputElement{ActionId: inputAction, Multiline: true}, true).
+		Blocks()
+
+	// remember the channel to confirm the card in
+	modal := slack.NewModal(addCallback, "New Trello card", "Create", blocks)
+	modal.PrivateMetadata = sc.ChannelId
 
+	cp := newPayload(sc)
+	cp.Modal = modal
+
+	return cp, nil
 }
 
+// createCard handles the add card modal. Unknown boards and lists are
+// shown as errors in the modal.
+func createCard(ctx context.Context, in *slack.Interaction) (*slack.CommandPayload, error) {
+	state := in.View.State
+	boardName := strings.TrimSpace(state.Value(boardInput, inputAction))
+	listName := strings.TrimSpace(state.Value(listInput, inputAction))
+	title := strings.TrimSpace(state.Value(titleInput, inputAction))
+
+	if title == "" {
+		return nil, slack.ValidationErrors{titleInput: "A card needs a title"}
+	}
+
+	deps := slack.DepsFrom(ctx)
+	settings := deps.Settings
+	trelloKey, trelloToken, err := credentials(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	boardsURL := fmt.Sprintf(
+		"https://api.trello.com/1/organizations/%v/boards/?fields=name&filter=open&key=%v&token=%v",
+		settings.Get("org"),
+		trelloKey,
+		trelloToken,
+	)
+
+	var boards []board
+	err = getJSON(ctx, deps.HTTPClient, boardsURL, &boards)
+	if err != nil {
+		return nil, err
+	}
+
+	var foundBoard board
+	for _, board := range boards {
+		if strings.EqualFold(board.Name, boardName) {
+			foundBoard = board
+			break
+		}
+	}
+	if foundBoard.Id == "" {
+		return nil, slack.ValidationErrors{boardInput: fmt.Sprintf("No %v board is named %q", settings.Get("org"), boardName)}
+	}
+
+	listsURL := fmt.Sprintf(
+		"https://api.trello.com/1/boards/%v/lists/?fields=name,idBoard&key=%v&token=%v",
+		foundBoard.Id,
+		trelloKey,
+		trelloToken,
+	)
+
+	var lists []list
+	err = getJSON(ctx, deps.HTTPClient, listsURL, &lists)
+	if err != nil {
+		return nil, err
+	}
+
+	var foundList list
+	for _, list := range lists {
+		if strings.EqualFold(list.Name, listName) {
+			foundList = list
+			break
+		}
+	}
+	if foundList.Id == "" {
+		return nil, slack.ValidationErrors{listInput: fmt.Sprintf("%v has no list named %q", foundBoard.Name, listName)}
+	}
+
+	cardURL := fmt.Sprintf(
+		"https://api.trello.com/1/cards?idList=%v&name=%v&desc=%v&key=%v&token=%v",
+		foundList.Id,
+		url.QueryEscape(title),
+		url.QueryEscape(state.Value(descInput, inputAction)),
+		trelloKey,
+		trelloToken,
+	)
+
+	var created struct {
+		ShortUrl string
+	}
+	err = doJSON(ctx, deps.HTTPClient, "POST", cardURL, &created)
+	if err != nil {
+		return nil, err
+	}
+
+	// confirm in the channel /fg add was used in
+	channel := in.View.PrivateMetadata
+	if in.Client == nil || channel == "" {
+		return nil, nil
+	}
+
+	text := fmt.Sprintf("Created <%v|%v> in %v › %v", created.ShortUrl, title, foundBoard.Name, foundList.Name)
+	_, err = in.Client.PostEphemeral(ctx, channel, in.User.Id, &slack.CommandPayload{Text: text})
+
+	return nil, err
+}
+
+// rxTrelloLink matches the card and board links unfurl previews and
+// captures their kind and short ID
+var rxTrelloLink = regexp.MustCompile(`^https://trello\.com/(c|b)/([0-9A-Za-z]+)(?:/|$)`)
+
+// unfurl previews the Trello cards and boards shared in a message. The app
+// has to list trello.com under its unfurl domains.
+func unfurl(ctx context.Context, req *slack.EventRequest) (*slack.CommandPayload, error) {
+	deps := slack.DepsFrom(ctx)
+	trelloKey, trelloToken, err := credentials(deps.Settings)
+	if err != nil {
+		return nil, err
+	}
+
+	unfurls := make(map[string]slack.Attachment)
+	for _, link := range req.Event.Links {
+		m := rxTrelloLink.FindStringSubmatch(link.URL)
+		if m == nil {
+			continue
+		}
+
+		var (
+			apiURL  string
+			preview struct {
+				Name  string
+				Desc  string
+				Board struct {
+					Name string
+				}
+			}
+		)
+		if m[1] == "c" {
+			apiURL = fmt.Sprintf(
+				"https://api.trello.com/1/cards/%v?fields=name,desc&board=true&board_fields=name&key=%v&token=%v",
+				m[2],
+				trelloKey,
+				trelloToken,
+			)
+		} else {
+			apiURL = fmt.Sprintf(
+				"https://api.trello.com/1/boards/%v?fields=name,desc&key=%v&token=%v",
+				m[2],
+				trelloKey,
+				trelloToken,
+			)
+		}
+
+		err = getJSON(ctx, deps.HTTPClient, apiURL, &preview)
+		if err != nil {
+			// a private or deleted card just isn't previewed
+			slog.WarnContext(ctx, "trello: unfurl failed", "url", link.URL, "err", err)
+			continue
+		}
+
+		a := slack.Attachment{
+			Fallback:  preview.Name,
+			Title:     preview.Name,
+			TitleLink: link.URL,
+			Text:      preview.Desc,
+		}
+		if preview.Board.Name != "" {
+			a.Fields = []slack.Field{{Title: "Board", Value: preview.Board.Name, Short: true}}
+		}
+		unfurls[link.URL] = a
+	}
+
+	if len(unfurls) == 0 {
+		return nil, nil
+	}
+	return &slack.CommandPayload{Unfurls: unfurls}, nil
+}
+
+// getJSON requests url from Trello and decodes the response into v
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	return doJSON(ctx, client, "GET", url, v)
+}
+
+// doJSON sends a request to Trello and decodes the response into v
+func doJSON(ctx context.Context, client *http.Client, method string, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return err
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return slack.WrapError(slack.Upstream, fmt.Errorf("trello: %v", res.Status))
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+// formatForSlack shows s under the command that lists it. Names with
+// spaces are quoted the way they're typed.
 func formatForSlack(c []string, s string) string {
-	path := strings.Join(c, " ")
-	return fmt.Sprintf("/fg %v ```%v```", path, s)
+	path := make([]string, len(c))
+	for i, name := range c {
+		path[i] = quoteName(name)
+	}
+	return fmt.Sprintf("/fg %v ```%v```", strings.Join(path, " "), s)
+}
+
+// quoteName returns a board or list name as it's typed in a command
+func quoteName(name string) string {
+	if !strings.ContainsAny(name, " \t\"'\\‘’“”") {
+		return name
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
 }
 
 // IsUrl test if the rxURL regular expression matches a string
@@ -226,7 +635,7 @@ func (b board) String() string {
 	return fmt.Sprintf(
 		"• <https://trello.com/b/%v|%v> \n",
 		b.Id,
-		strings.Replace(b.Name, " ", "_", -1),
+		b.Name,
 	)
 }
 
@@ -241,7 +650,7 @@ func (l list) String() string {
 	return fmt.Sprintf(
 		"• <https://trello.com/b/%v|%v> \n",
 		l.IdBoard,
-		strings.Replace(l.Name, " ", "_", -1),
+		l.Name,
 	)
 }
 
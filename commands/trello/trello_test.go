@@ -0,0 +1,131 @@
+package trello
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/jesselucas/slackcmd/slack"
+)
+
+func TestFormatForSlack(t *testing.T) {
+	tests := []struct {
+		path     []string
+		expected string
+	}{
+		{nil, "/fg  ```cards```"},
+		{[]string{"Wiki"}, "/fg Wiki ```cards```"},
+		{[]string{"Design Team", "To Do"}, "/fg \"Design Team\" \"To Do\" ```cards```"},
+		{[]string{`Say "hi"`}, "/fg \"Say \\\"hi\\\"\" ```cards```"},
+	}
+
+	for _, test := range tests {
+		if s := formatForSlack(test.path, "cards"); s != test.expected {
+			t.Errorf("Test errored. formatForSlack(%q) should be %q but is %q", test.path, test.expected, s)
+		}
+	}
+}
+
+func TestQuoteName(t *testing.T) {
+	// names are shown so they can be typed back in
+	for _, name := range []string{"Wiki", "Design Team", `Say "hi"`, "Bob's board", "Bob’s", `back\slash`} {
+		tokens, err := slack.Tokenize(quoteName(name))
+		if err != nil || len(tokens) != 1 || tokens[0].Value != name {
+			t.Errorf("Test errored. %q should tokenize back to %q but is %v (%v)", quoteName(name), name, tokens, err)
+		}
+	}
+}
+
+func TestBoardString(t *testing.T) {
+	b := board{Name: "Design Team", Id: "b1"}
+	if s, expected := b.String(), "• <https://trello.com/b/b1|Design Team> \n"; s != expected {
+		t.Errorf("Test errored. Board should be %q but is %q", expected, s)
+	}
+
+	l := list{Name: "To Do", Id: "l1", IdBoard: "b1"}
+	if s, expected := l.String(), "• <https://trello.com/b/b1|To Do> \n"; s != expected {
+		t.Errorf("Test errored. List should be %q but is %q", expected, s)
+	}
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestUnfurl(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/1/cards/abc123":
+			w.Write([]byte(`{"name":"Fix login","desc":"It 500s","board":{"name":"Design Team"}}`))
+		case "/1/boards/xyz789":
+			w.Write([]byte(`{"name":"Design Team","desc":"Design work"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	// Trello is on api.trello.com, the client sends it to ts
+	target, _ := url.Parse(ts.URL)
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.URL.Scheme, r.URL.Host = target.Scheme, target.Host
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	ctx := slack.WithDeps(context.Background(), slack.Deps{
+		Settings:   slack.Settings{"key": "k", "token": "t"},
+		HTTPClient: client,
+	})
+
+	tests := []struct {
+		url   string
+		title string
+		board string
+	}{
+		{"https://trello.com/c/abc123/42-fix-login", "Fix login", "Design Team"},
+		{"https://trello.com/b/xyz789", "Design Team", ""},
+		{"https://trello.com/c/gone", "", ""}, // private or deleted
+		{"https://trello.com/u/someone", "", ""},
+		{"https://example.com/c/abc123", "", ""},
+	}
+
+	var req slack.EventRequest
+	req.Event.Type = slack.LinkShared
+	for _, test := range tests {
+		req.Event.Links = append(req.Event.Links, slack.Link{Domain: "trello.com", URL: test.url})
+	}
+
+	cp, err := unfurl(ctx, &req)
+	if err != nil {
+		t.Fatal("Test errored. unfurl returned", err)
+	}
+	for _, test := range tests {
+		a, ok := cp.Unfurls[test.url]
+		if ok != (test.title != "") {
+			t.Errorf("Test errored. %v unfurled should be %v but is %v", test.url, test.title != "", ok)
+		}
+		if !ok {
+			continue
+		}
+		if a.Title != test.title || a.TitleLink != test.url {
+			t.Errorf("Test errored. %v title should be %q but is %q", test.url, test.title, a.Title)
+		}
+		var board string
+		if len(a.Fields) > 0 {
+			board = a.Fields[0].Value
+		}
+		if board != test.board {
+			t.Errorf("Test errored. %v board should be %q but is %q", test.url, test.board, board)
+		}
+	}
+
+	// nothing to preview posts nothing
+	req.Event.Links = []slack.Link{{Domain: "example.com", URL: "https://example.com"}}
+	cp, err = unfurl(ctx, &req)
+	if cp != nil || err != nil {
+		t.Errorf("Test errored. unfurl with no Trello links should be nil but is %v %v", cp, err)
+	}
+}
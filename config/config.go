@@ -0,0 +1,384 @@
+// Package config loads slackcmd's configuration from a YAML, TOML or JSON
+// file. String values may reference env vars as ${VAR} and env vars
+// override the file.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Files looked for by Find in order
+var Files = []string{"config.yaml", "config.yml", "config.toml", "config.json"}
+
+type Config struct {
+	Server    Server                     `yaml:"server" toml:"server" json:"server"`
+	Slack     Slack                      `yaml:"slack" toml:"slack" json:"slack"`
+	Storage   Storage                    `yaml:"storage" toml:"storage" json:"storage"`
+	Log       Log                        `yaml:"log" toml:"log" json:"log"`
+	RateLimit RateLimit                  `yaml:"rate_limit" toml:"rate_limit" json:"rate_limit"`
+	Commands  map[string]CommandSettings `yaml:"commands" toml:"commands" json:"commands"`
+
+	// Path is the file the config was loaded from, "" for env only
+	Path string `yaml:"-" toml:"-" json:"-"`
+	// Unset lists the ${VAR} references that weren't set
+	Unset []string `yaml:"-" toml:"-" json:"-"`
+}
+
+type Server struct {
+	Addr string `yaml:"addr" toml:"addr" json:"addr"`
+
+	ReadTimeout     Duration `yaml:"read_timeout" toml:"read_timeout" json:"read_timeout"`
+	WriteTimeout    Duration `yaml:"write_timeout" toml:"write_timeout" json:"write_timeout"`
+	IdleTimeout     Duration `yaml:"idle_timeout" toml:"idle_timeout" json:"idle_timeout"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" json:"shutdown_timeout"`
+	MaxBodyBytes    int64    `yaml:"max_body_bytes" toml:"max_body_bytes" json:"max_body_bytes"`
+
+	// TLS is served with the cert and key files, or certificates from
+	// Let's Encrypt for AutocertHosts
+	TLSCert       string `yaml:"tls_cert" toml:"tls_cert" json:"tls_cert"`
+	TLSKey        string `yaml:"tls_key" toml:"tls_key" json:"tls_key"`
+	AutocertHosts string `yaml:"autocert_hosts" toml:"autocert_hosts" json:"autocert_hosts"` // comma separated
+	AutocertCache string `yaml:"autocert_cache" toml:"autocert_cache" json:"autocert_cache"`
+
+	// PanicLimit turns a command off after that many panics in a row, 0
+	// never does. AdminToken authorizes turning it back on.
+	PanicLimit int    `yaml:"panic_limit" toml:"panic_limit" json:"panic_limit"`
+	AdminToken string `yaml:"admin_token" toml:"admin_token" json:"admin_token"`
+}
+
+// Server defaults
+const (
+	DefaultReadTimeout     = 10 * time.Second
+	DefaultWriteTimeout    = 35 * time.Second // longer than slack.DefaultTimeout so late replies are written
+	DefaultIdleTimeout     = 2 * time.Minute
+	DefaultShutdownTimeout = 30 * time.Second
+	DefaultMaxBodyBytes    = 1 << 20
+)
+
+// Duration is a time.Duration written as a string, Ex. "30s"
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	var err error
+	d.Duration, err = time.ParseDuration(string(text))
+	return err
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	err := unmarshal(&s)
+	if err != nil {
+		return err
+	}
+	return d.UnmarshalText([]byte(s))
+}
+
+type Slack struct {
+	SigningSecret     string `yaml:"signing_secret" toml:"signing_secret" json:"signing_secret"`
+	VerificationToken string `yaml:"verification_token" toml:"verification_token" json:"verification_token"`
+	BotToken          string `yaml:"bot_token" toml:"bot_token" json:"bot_token"`
+	WebhookURL        string `yaml:"webhook_url" toml:"webhook_url" json:"webhook_url"`
+	ClientId          string `yaml:"client_id" toml:"client_id" json:"client_id"`
+	ClientSecret      string `yaml:"client_secret" toml:"client_secret" json:"client_secret"`
+	Scopes            string `yaml:"scopes" toml:"scopes" json:"scopes"`
+	RedirectURL       string `yaml:"redirect_url" toml:"redirect_url" json:"redirect_url"`
+	StateSecret       string `yaml:"state_secret" toml:"state_secret" json:"state_secret"`
+}
+
+type Storage struct {
+	Driver string `yaml:"driver" toml:"driver" json:"driver"`
+	Path   string `yaml:"path" toml:"path" json:"path"`
+}
+
+type Log struct {
+	Level  string `yaml:"level" toml:"level" json:"level"`    // debug, info, warn or error
+	Format string `yaml:"format" toml:"format" json:"format"` // json or text
+}
+
+// RateLimit limits how often each user, channel and team can run a
+// command, and how often a command runs in total
+type RateLimit struct {
+	User    Rate `yaml:"user" toml:"user" json:"user"`
+	Channel Rate `yaml:"channel" toml:"channel" json:"channel"`
+	Team    Rate `yaml:"team" toml:"team" json:"team"`
+	Command Rate `yaml:"command" toml:"command" json:"command"`
+}
+
+// Rate is Requests per Per with bursts of up to Burst requests, Ex.
+// {requests: 10, per: 1m, burst: 3}. No Requests is no limit.
+type Rate struct {
+	Requests int      `yaml:"requests" toml:"requests" json:"requests"`
+	Per      Duration `yaml:"per" toml:"per" json:"per"`
+	Burst    int      `yaml:"burst" toml:"burst" json:"burst"`
+}
+
+// CommandSettings are the settings of one command. Numbers and booleans
+// are read as strings, Ex. board_limit: 10 is "10".
+type CommandSettings map[string]string
+
+func (s *CommandSettings) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var m map[string]interface{}
+	err := unmarshal(&m)
+	if err != nil {
+		return err
+	}
+	return s.set(m)
+}
+
+func (s *CommandSettings) UnmarshalTOML(v interface{}) error {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("command settings must be a table, not %T", v)
+	}
+	return s.set(m)
+}
+
+func (s *CommandSettings) UnmarshalJSON(b []byte) error {
+	var m map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	err := d.Decode(&m)
+	if err != nil {
+		return err
+	}
+	return s.set(m)
+}
+
+// set stores the scalar values of m as strings
+func (s *CommandSettings) set(m map[string]interface{}) error {
+	*s = make(CommandSettings, len(m))
+	for key, value := range m {
+		switch v := value.(type) {
+		case nil:
+			(*s)[key] = ""
+		case string:
+			(*s)[key] = v
+		case bool, int, int64, uint64, float64, json.Number:
+			(*s)[key] = fmt.Sprint(v)
+		default:
+			return fmt.Errorf("setting %q must be a string, number or boolean", key)
+		}
+	}
+	return nil
+}
+
+// Section returns the settings of a command, Ex. Section("qotd")
+func (c *Config) Section(name string) map[string]string {
+	return c.Commands[name]
+}
+
+// overrides returns the env var for each value that can be overridden
+func (c *Config) overrides() map[string]*string {
+	return map[string]*string{
+		"SLACKCMD_ADDR":            &c.Server.Addr,
+		"SLACKCMD_TLS_CERT":        &c.Server.TLSCert,
+		"SLACKCMD_TLS_KEY":         &c.Server.TLSKey,
+		"SLACKCMD_AUTOCERT_HOSTS":  &c.Server.AutocertHosts,
+		"SLACKCMD_ADMIN_TOKEN":     &c.Server.AdminToken,
+		"SLACK_SIGNING_SECRET":     &c.Slack.SigningSecret,
+		"SLACK_VERIFICATION_TOKEN": &c.Slack.VerificationToken,
+		"SLACK_BOT_TOKEN":          &c.Slack.BotToken,
+		"SLACK_WEBHOOK_URL":        &c.Slack.WebhookURL,
+		"SLACK_CLIENT_ID":          &c.Slack.ClientId,
+		"SLACK_CLIENT_SECRET":      &c.Slack.ClientSecret,
+		"SLACK_SCOPES":             &c.Slack.Scopes,
+		"SLACK_REDIRECT_URL":       &c.Slack.RedirectURL,
+		"SLACK_STATE_SECRET":       &c.Slack.StateSecret,
+		"STORAGE_DRIVER":           &c.Storage.Driver,
+		"STORAGE_PATH":             &c.Storage.Path,
+		"LOG_LEVEL":                &c.Log.Level,
+		"LOG_FORMAT":               &c.Log.Format,
+	}
+}
+
+// applyEnv overrides the file with env vars and fills in defaults
+func (c *Config) applyEnv() {
+	for env, value := range c.overrides() {
+		if v := os.Getenv(env); v != "" {
+			*value = v
+		}
+	}
+
+	// PORT is set by hosts like Heroku
+	if port := os.Getenv("PORT"); port != "" && os.Getenv("SLACKCMD_ADDR") == "" {
+		c.Server.Addr = ":" + port
+	}
+	if c.Server.Addr == "" {
+		c.Server.Addr = "localhost:8080"
+		if c.Server.AutocertHosts != "" {
+			c.Server.Addr = ":443"
+		}
+	}
+	for _, d := range []struct {
+		value *Duration
+		def   time.Duration
+	}{
+		{&c.Server.ReadTimeout, DefaultReadTimeout},
+		{&c.Server.WriteTimeout, DefaultWriteTimeout},
+		{&c.Server.IdleTimeout, DefaultIdleTimeout},
+		{&c.Server.ShutdownTimeout, DefaultShutdownTimeout},
+	} {
+		if d.value.Duration == 0 {
+			d.value.Duration = d.def
+		}
+	}
+	if c.Server.MaxBodyBytes == 0 {
+		c.Server.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if c.Storage.Driver == "" {
+		c.Storage.Driver = "memory"
+	}
+	if c.Log.Level == "" {
+		c.Log.Level = "info"
+	}
+	if c.Log.Format == "" {
+		c.Log.Format = "json"
+	}
+	for _, r := range []*Rate{&c.RateLimit.User, &c.RateLimit.Channel, &c.RateLimit.Team, &c.RateLimit.Command} {
+		if r.Requests > 0 && r.Per.Duration == 0 {
+			r.Per.Duration = time.Minute
+		}
+	}
+}
+
+// Find returns the config file named by SLACKCMD_CONFIG or the first of
+// Files in the working directory, "" if there is none
+func Find() string {
+	if path := os.Getenv("SLACKCMD_CONFIG"); path != "" {
+		return path
+	}
+
+	for _, f := range Files {
+		if _, err := os.Stat(f); err == nil {
+			return f
+		}
+	}
+	return ""
+}
+
+// Load reads the config file at path, or only env vars when path is "".
+// The format is chosen by the extension. A legacy config.json holding a
+// list of {"Key": "", "Value": ""} pairs sets those env vars instead.
+func Load(path string) (*Config, error) {
+	c := &Config{Path: path}
+
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		err = decode(path, b, c)
+		if err != nil {
+			return nil, fmt.Errorf("config: %v: %v", path, err)
+		}
+
+		// after decoding so values can't change the file's structure
+		interpolate(reflect.ValueOf(c), &c.Unset)
+	}
+
+	c.applyEnv()
+	return c, nil
+}
+
+// decode unmarshals b into c by the file extension
+func decode(path string, b []byte, c *Config) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.UnmarshalStrict(b, c)
+	case ".toml":
+		md, err := toml.Decode(string(b), c)
+		if err != nil {
+			return err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown key %v", undecoded[0])
+		}
+		return nil
+	case ".json":
+		if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '[' {
+			return setLegacyEnv(trimmed)
+		}
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		return d.Decode(c)
+	}
+
+	return fmt.Errorf("unknown config format %q", filepath.Ext(path))
+}
+
+// setLegacyEnv sets the env vars of the original config.json format
+func setLegacyEnv(b []byte) error {
+	var envVars []struct {
+		Key   string
+		Value string
+	}
+	err := json.Unmarshal(b, &envVars)
+	if err != nil {
+		return err
+	}
+
+	for _, env := range envVars {
+		if env.Key == "" {
+			return fmt.Errorf("legacy config entry without a Key")
+		}
+		os.Setenv(env.Key, env.Value)
+	}
+	return nil
+}
+
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// interpolate replaces ${VAR} in the string values of v with the value of
+// the env var and adds the names of the vars that aren't set to unset
+func interpolate(v reflect.Value, unset *[]string) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		interpolate(v.Elem(), unset)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).Tag.Get("yaml") != "-" {
+				interpolate(v.Field(i), unset)
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			value := v.MapIndex(key)
+			if value.Kind() == reflect.String {
+				v.SetMapIndex(key, reflect.ValueOf(expand(value.String(), unset)).Convert(value.Type()))
+				continue
+			}
+			interpolate(value, unset)
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(expand(v.String(), unset))
+		}
+	}
+}
+
+// expand replaces ${VAR} in s with the value of the env var
+func expand(s string, unset *[]string) string {
+	return envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envRef.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			*unset = append(*unset, name)
+		}
+		return value
+	})
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const yamlConfig = `
+server:
+  addr: ":9000"
+  write_timeout: 1m
+slack:
+  signing_secret: ${SLACKCMD_TEST_SECRET}
+  bot_token: ${SLACKCMD_TEST_MISSING}
+storage:
+  driver: bolt
+rate_limit:
+  user: {requests: 10, burst: 3}
+commands:
+  trello:
+    org: forestgiant
+    board_limit: 10
+    archived: false
+    title: ${SLACKCMD_TEST_TITLE}
+`
+
+const tomlConfig = `
+[server]
+addr = ":9000"
+write_timeout = "1m"
+
+[slack]
+signing_secret = "${SLACKCMD_TEST_SECRET}"
+
+[storage]
+driver = "bolt"
+
+[rate_limit.user]
+requests = 10
+burst = 3
+
+[commands.trello]
+org = "forestgiant"
+board_limit = 10
+archived = false
+title = "${SLACKCMD_TEST_TITLE}"
+`
+
+const jsonConfig = `{
+	"server": {"addr": ":9000", "write_timeout": "1m"},
+	"slack": {"signing_secret": "${SLACKCMD_TEST_SECRET}"},
+	"storage": {"driver": "bolt"},
+	"rate_limit": {"user": {"requests": 10, "burst": 3}},
+	"commands": {"trello": {"org": "forestgiant", "board_limit": 10, "archived": false, "title": "${SLACKCMD_TEST_TITLE}"}}
+}`
+
+func writeConfig(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	err := ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Setenv("SLACKCMD_TEST_SECRET", "shh")
+	defer os.Unsetenv("SLACKCMD_TEST_SECRET")
+
+	// values are substituted after decoding, so they can't add keys or
+	// break the file
+	title := "a \"quoted\" #title: with\nstorage:\n  driver: sqlite\n}]"
+	os.Setenv("SLACKCMD_TEST_TITLE", title)
+	defer os.Unsetenv("SLACKCMD_TEST_TITLE")
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"config.yaml", yamlConfig},
+		{"config.toml", tomlConfig},
+		{"config.json", jsonConfig},
+	}
+
+	for _, test := range tests {
+		c, err := Load(writeConfig(t, dir, test.name, test.content))
+		if err != nil {
+			t.Errorf("Test errored. %v returned %v", test.name, err)
+			continue
+		}
+
+		if c.Server.Addr != ":9000" {
+			t.Errorf("Test errored. %v addr should be :9000 but is %v", test.name, c.Server.Addr)
+		}
+		if c.Server.WriteTimeout.Duration != time.Minute || c.Server.ReadTimeout.Duration != DefaultReadTimeout {
+			t.Errorf("Test errored. %v timeouts should be 1m and %v but are %v and %v", test.name, DefaultReadTimeout, c.Server.WriteTimeout, c.Server.ReadTimeout)
+		}
+		if c.Slack.SigningSecret != "shh" {
+			t.Errorf("Test errored. %v signing secret should be interpolated but is %q", test.name, c.Slack.SigningSecret)
+		}
+		if c.Storage.Driver != "bolt" {
+			t.Errorf("Test errored. %v driver should be bolt but is %v", test.name, c.Storage.Driver)
+		}
+		if user := c.RateLimit.User; user.Requests != 10 || user.Per.Duration != time.Minute || user.Burst != 3 || c.RateLimit.Team.Requests != 0 {
+			t.Errorf("Test errored. %v user rate limit should be 10 per 1m with a burst of 3 but is %+v", test.name, user)
+		}
+		trello := c.Section("trello")
+		if trello["org"] != "forestgiant" || trello["board_limit"] != "10" || trello["archived"] != "false" {
+			t.Errorf("Test errored. %v trello settings should be read as strings but are %v", test.name, trello)
+		}
+		if trello["title"] != title {
+			t.Errorf("Test errored. %v trello title should be %q but is %q", test.name, title, trello["title"])
+		}
+	}
+
+	c, _ := Load(filepath.Join(dir, "config.yaml"))
+	if len(c.Unset) != 1 || c.Unset[0] != "SLACKCMD_TEST_MISSING" {
+		t.Errorf("Test errored. Unset should be [SLACKCMD_TEST_MISSING] but is %v", c.Unset)
+	}
+}
+
+func TestLoadEnvOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Setenv("STORAGE_DRIVER", "sqlite")
+	os.Setenv("PORT", "5000")
+	defer os.Unsetenv("STORAGE_DRIVER")
+	defer os.Unsetenv("PORT")
+
+	c, err := Load(writeConfig(t, dir, "config.yaml", yamlConfig))
+	if err != nil {
+		t.Fatal("Test errored. Load returned", err)
+	}
+	if c.Storage.Driver != "sqlite" {
+		t.Errorf("Test errored. Driver should be sqlite but is %v", c.Storage.Driver)
+	}
+	if c.Server.Addr != ":5000" {
+		t.Errorf("Test errored. Addr should be :5000 but is %v", c.Server.Addr)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"config.yaml", "server: [1"},
+		{"config.yaml", "sevrer:\n  addr: :9000\n"}, // unknown keys are typos
+		{"config.json", `{"server": `},
+		{"config.toml", "[sevrer]\naddr = \":9000\"\n"},
+		{"config.yaml", "commands:\n  trello:\n    boards: [Dev, Design]\n"},
+		{"config.json", `{"commands": {"trello": {"org": {"name": "fg"}}}}`},
+		{"config.yaml", "server:\n  read_timeout: soon\n"},
+		{"config.ini", "addr = :9000"},
+	}
+
+	for _, test := range tests {
+		_, err := Load(writeConfig(t, dir, test.name, test.content))
+		if err == nil {
+			t.Errorf("Test errored. %q should not load", test.content)
+		}
+	}
+}
+
+func TestLoadLegacyJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv("SLACK_BOT_TOKEN")
+
+	c, err := Load(writeConfig(t, dir, "config.json", `[{"Key": "SLACK_BOT_TOKEN", "Value": "xoxb-legacy"}]`))
+	if err != nil {
+		t.Fatal("Test errored. Load returned", err)
+	}
+	if c.Slack.BotToken != "xoxb-legacy" {
+		t.Errorf("Test errored. Bot token should be set from the legacy env list but is %q", c.Slack.BotToken)
+	}
+}
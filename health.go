@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/jesselucas/slackcmd/slack"
+	"github.com/jesselucas/slackcmd/storage"
+)
+
+// Set at build time with -ldflags "-X main.version=v1.2.0 -X main.commit=abc123"
+var (
+	version = "dev"
+	commit  = ""
+)
+
+const (
+	// readyTimeout limits how long the readiness checks run
+	readyTimeout = 5 * time.Second
+	// readyCacheTTL keeps frequent probes from hammering Trello and Google
+	readyCacheTTL = 15 * time.Second
+)
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// healthHandler reports the process is up
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// commandStatus is the readiness of one command
+type commandStatus struct {
+	Status  string   `json:"status"` // ok, not configured, suspended or unavailable
+	Missing []string `json:"missing,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+type readiness struct {
+	Status   string                   `json:"status"`
+	Storage  string                   `json:"storage"`
+	Commands map[string]commandStatus `json:"commands"`
+	Checked  time.Time                `json:"checked"`
+}
+
+var (
+	readyMu   sync.Mutex
+	lastReady *readiness
+)
+
+// readyHandler reports whether the storage is reachable and every command
+// is configured and can reach the services it calls. It answers 503 when
+// any of them isn't ready.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	readyMu.Lock()
+	if lastReady == nil || time.Since(lastReady.Checked) > readyCacheTTL {
+		// the result is shared, so a probe that gave up mustn't cut it short
+		ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+		lastReady = checkReady(ctx)
+		cancel()
+	}
+	ready := lastReady
+	readyMu.Unlock()
+
+	status := http.StatusOK
+	if ready.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, ready)
+}
+
+// checkReady runs the storage and command checks concurrently
+func checkReady(ctx context.Context) *readiness {
+	ready := &readiness{
+		Status:   "ok",
+		Storage:  "ok",
+		Commands: make(map[string]commandStatus),
+		Checked:  time.Now(),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := store.Get(ctx, "slackcmd:readyz")
+		if err != nil && err != storage.ErrNotFound {
+			mu.Lock()
+			ready.Status, ready.Storage = "unavailable", err.Error()
+			mu.Unlock()
+		}
+	}()
+
+	for _, ci := range slack.Commands() {
+		ci := ci
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cs := checkCommand(ctx, ci)
+
+			mu.Lock()
+			ready.Commands[ci.Name()] = cs
+			if cs.Status != "ok" {
+				ready.Status = "unavailable"
+			}
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+	return ready
+}
+
+func checkCommand(ctx context.Context, ci *slack.CommandInfo) commandStatus {
+	section := ci.ConfigSection()
+	if missing := missingFor(section); len(missing) > 0 {
+		cs := commandStatus{Status: "not configured"}
+		for _, m := range missing {
+			cs.Missing = append(cs.Missing, "commands."+section+"."+m.Key)
+		}
+		return cs
+	}
+
+	if isSuspended(ci.Name()) {
+		return commandStatus{Status: "suspended", Error: "turned off after repeated panics"}
+	}
+
+	if ci.Check != nil {
+		err := ci.Check(ctx, depsFor(section))
+		if err != nil {
+			return commandStatus{Status: "unavailable", Error: err.Error()}
+		}
+	}
+
+	return commandStatus{Status: "ok"}
+}
+
+// versionHandler reports the build and the commands served
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	type command struct {
+		Names       []string `json:"names"`
+		Description string   `json:"description"`
+	}
+
+	v := struct {
+		Version   string    `json:"version"`
+		Commit    string    `json:"commit,omitempty"`
+		Module    string    `json:"module,omitempty"`
+		GoVersion string    `json:"go_version"`
+		BuildTime string    `json:"build_time,omitempty"`
+		Modified  bool      `json:"modified,omitempty"`
+		Commands  []command `json:"commands"`
+	}{
+		Version:   version,
+		Commit:    commit,
+		GoVersion: runtime.Version(),
+		Commands:  []command{},
+	}
+
+	// fill in what -ldflags didn't from the module and VCS build info
+	if info, ok := debug.ReadBuildInfo(); ok {
+		v.Module = info.Main.Path
+		if v.Version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v.Version = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if v.Commit == "" {
+					v.Commit = s.Value
+				}
+			case "vcs.time":
+				v.BuildTime = s.Value
+			case "vcs.modified":
+				v.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	for _, ci := range slack.Commands() {
+		v.Commands = append(v.Commands, command{ci.Names, ci.Description})
+	}
+
+	writeJSON(w, http.StatusOK, v)
+}
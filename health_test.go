@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jesselucas/slackcmd/slack"
+)
+
+func init() {
+	// fails when the readiness checks are cut short
+	slack.Register(slack.CommandInfo{
+		Names:    []string{"/testcheck"},
+		TokenEnv: "SLACK_KEY_TESTCHECK",
+		New:      func(slack.Deps) slack.Command { return testCommand{} },
+		Check: func(ctx context.Context, deps slack.Deps) error {
+			return ctx.Err()
+		},
+	})
+}
+
+// resetReady drops the cached readiness before and after the test
+func resetReady(t *testing.T) {
+	reset := func() {
+		readyMu.Lock()
+		lastReady = nil
+		readyMu.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestHealthHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	healthHandler(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `{"status":"ok"}`+"\n" {
+		t.Errorf("Test errored. /healthz should be ok but is %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	resetReady(t)
+
+	// a probe that already gave up doesn't cache a failed check
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest("GET", "/readyz", nil).WithContext(ctx)
+	readyHandler(httptest.NewRecorder(), r)
+
+	// only the test commands are configured, so the overall status is only
+	// checked when it's unavailable either way
+	tests := []struct {
+		suspend  string
+		status   int
+		command  string
+		expected string
+	}{
+		{"", 0, "/testcheck", "ok"},
+		{"/testok", http.StatusServiceUnavailable, "/testok", "suspended"},
+	}
+
+	for _, test := range tests {
+		resetPanics(t)
+		if test.suspend != "" {
+			trackPanics(context.Background(), test.suspend, &slack.PanicError{Value: "boom"}, 1)
+			resetReady(t)
+		}
+
+		w := httptest.NewRecorder()
+		readyHandler(w, httptest.NewRequest("GET", "/readyz", nil))
+
+		var ready readiness
+		json.NewDecoder(w.Body).Decode(&ready)
+		if test.status != 0 && w.Code != test.status {
+			t.Errorf("Test errored. Status should be %v but is %v (%+v)", test.status, w.Code, ready)
+		}
+		if cs := ready.Commands[test.command]; cs.Status != test.expected {
+			t.Errorf("Test errored. %v should be %q but is %q (%v)", test.command, test.expected, cs.Status, cs.Error)
+		}
+	}
+}
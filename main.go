@@ -1,183 +1,606 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"time"
 
-	"github.com/jesselucas/slackcmd/commands/beats1"
-	"github.com/jesselucas/slackcmd/commands/calendar"
-	"github.com/jesselucas/slackcmd/commands/qotd"
-	"github.com/jesselucas/slackcmd/commands/trello"
+	"github.com/jesselucas/slackcmd/config"
+	"github.com/jesselucas/slackcmd/logging"
+	"github.com/jesselucas/slackcmd/metrics"
 	"github.com/jesselucas/slackcmd/slack"
+	"github.com/jesselucas/slackcmd/storage"
+	"github.com/jesselucas/slackcmd/storage/bolt"
+	"github.com/jesselucas/slackcmd/storage/sqlite"
+
+	// Commands register themselves with slack.Register. Add commands here
+	_ "github.com/jesselucas/slackcmd/commands/beats1"
+	_ "github.com/jesselucas/slackcmd/commands/calendar"
+	_ "github.com/jesselucas/slackcmd/commands/qotd"
+	_ "github.com/jesselucas/slackcmd/commands/trello"
 )
 
-// struct used to store environment variables from config.json
-type env struct {
-	Key   string
-	Value string
-}
+// bot calls the Web API with SLACK_BOT_TOKEN. It is nil without a token.
+var bot *slack.Client
 
-func main() {
-	// setup environment variables if a config json exist
-	setEnvFromJSON("config.json")
+// upstream is the http.Client commands and replies use. It records the
+// latency and status of every call for /metrics and sends the correlation
+// ID of the request.
+var upstream = &http.Client{Transport: logging.Transport(metrics.Transport(nil))}
 
-	// url setup. FIX make more generic
-	var url string
-	if os.Getenv("PORT") != "" {
-		url = ":" + os.Getenv("PORT")
-	} else {
-		url = "localhost:8080"
-	}
+// store backs the storage commands are given in slack.Deps
+var store storage.Store
 
-	// vs := validateSlackToken(http.HandlerFunc(commandHandler), slackAPIKey)
-	http.HandleFunc("/cmd/", commandHandler)
-	http.HandleFunc("/cmd", commandHandler)
+// installations holds the bot tokens of workspaces that installed the app
+// with OAuth. It is nil when OAuth isn't configured.
+var installations slack.InstallationStore
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, "Go away!")
-	})
-	log.Fatal(http.ListenAndServe(url, nil))
-}
+func main() {
+	// read the config file, Ex. config.yaml, and env var overrides
+	path := config.Find()
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-func setEnvFromJSON(configPath string) {
-	configFile, err := ioutil.ReadFile(configPath)
+	// log JSON with secrets redacted. The log package writes through it too.
+	logger, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
 	if err != nil {
-		fmt.Println("config.json not found. Using os environment variables.")
-		return
+		log.Fatal(err)
 	}
+	slog.SetDefault(logger)
 
-	var envVars []env
-	json.Unmarshal(configFile, &envVars)
+	if path == "" {
+		slog.Info("No config file found. Using os environment variables.")
+	}
 
-	// set environment variables
-	for _, env := range envVars {
-		// fmt.Println(env)
-		os.Setenv(env.Key, env.Value)
+	// list the commands this server responds to
+	for _, ci := range slack.Commands() {
+		slog.Info("serving", "command", strings.Join(ci.Names, ", "), "description", ci.Description)
 	}
 
-}
+	// report missing settings now rather than on the first request
+	applySettings(cfg)
+	go reloadOnHangup(cfg)
 
-func createSlashCommand(w http.ResponseWriter, r *http.Request) *slack.SlashCommand {
-	var v url.Values
+	// verify requests are signed by Slack before they reach a command
+	signingSecret := cfg.Slack.SigningSecret
+	if signingSecret == "" {
+		slog.Warn("slack.signing_secret not set. Using legacy verification tokens.")
+	}
+	if token := cfg.Slack.BotToken; token != "" {
+		bot = slack.NewClient(token)
+		bot.HTTPClient = upstream
+	}
 
-	switch r.Method {
-	case "POST":
-		r.ParseForm()
-		v = r.Form
-	case "GET":
-		v = r.URL.Query()
+	store, err = openStore(cfg.Storage.Driver, cfg.Storage.Path)
+	if err != nil {
+		slog.Error("opening storage", "driver", cfg.Storage.Driver, "err", err)
+		os.Exit(1)
 	}
+	defer store.Close()
+
+	// serve several workspaces by installing the app with OAuth
+	if cfg.Slack.ClientId != "" {
+		installations = slack.NewStoredInstallations(storage.Namespace(store, "slackcmd"))
+		oauth := &slack.OAuth{
+			ClientId:     cfg.Slack.ClientId,
+			ClientSecret: cfg.Slack.ClientSecret,
+			Scopes:       strings.Split(cfg.Slack.Scopes, ","),
+			RedirectURL:  cfg.Slack.RedirectURL,
+			StateSecret:  cfg.Slack.StateSecret,
+			Store:        installations,
+		}
+		http.Handle("/install", oauth.InstallHandler())
+		http.Handle("/oauth/callback", oauth.CallbackHandler())
+
+		slack.HandleEvent("", "app_uninstalled", uninstall)
+		slack.HandleEvent("", "tokens_revoked", revoke)
+	}
+
+	vs := slack.VerifyRequests(signingSecret, http.HandlerFunc(commandHandler))
+	http.Handle("/cmd/", vs)
+	http.Handle("/cmd", vs)
+
+	// buttons, menus, modals and shortcuts registered by commands
+	http.Handle("/interact", slack.VerifyRequests(signingSecret,
+		slack.InteractionHandler(cfg.Slack.VerificationToken, clientFor, depsFor, guard, upstream)))
 
-	sc := &slack.SlashCommand{
-		Token:       v.Get("token"),
-		TeamId:      v.Get("team_id"),
-		TeamDomain:  v.Get("team_domain"),
-		ChannelId:   v.Get("channel_id"),
-		ChannelName: v.Get("channel_name"),
-		UserId:      v.Get("user_id"),
-		UserName:    v.Get("user_name"),
-		Command:     v.Get("command"),
-		Text:        v.Get("text"),
-		Hook:        v.Get("hook"),
+	// events commands subscribe to. Replies are posted with the workspace's
+	// bot token or an incoming webhook without one.
+	http.Handle("/events", slack.VerifyRequests(signingSecret,
+		slack.EventsHandler(cfg.Slack.VerificationToken, replyEvent(cfg.Slack.WebhookURL), depsFor, guard)))
+
+	// probes for load balancers and deploy tooling
+	http.HandleFunc("/healthz", healthHandler)
+	http.HandleFunc("/readyz", readyHandler)
+	http.HandleFunc("/version", versionHandler)
+	http.Handle("/metrics", metrics.Handler())
+
+	limits = newLimits(cfg.RateLimit)
+
+	// commands turned off after repeated panics are turned back on here
+	panicLimit = cfg.Server.PanicLimit
+	if cfg.Server.AdminToken != "" {
+		http.Handle("/admin/enable", enableHandler(cfg.Server.AdminToken))
 	}
 
-	return sc
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Go away!")
+	})
+
+	srv, err := newServer(cfg.Server, http.DefaultServeMux)
+	if err != nil {
+		slog.Error("configuring server", "err", err)
+		os.Exit(1)
+	}
+	err = serve(srv, cfg.Server)
+	if err != nil {
+		slog.Error("server stopped", "err", err)
+	}
 }
 
-func commandHandler(w http.ResponseWriter, r *http.Request) {
-	sc := createSlashCommand(w, r)
+// openStore opens the storage backend commands share. driver is memory,
+// bolt or sqlite and path is the database file.
+func openStore(driver string, path string) (storage.Store, error) {
+	switch driver {
+	case "", "memory":
+		return storage.NewMemory(), nil
+	case "bolt":
+		if path == "" {
+			path = "slackcmd.db"
+		}
+		return bolt.Open(path)
+	case "sqlite":
+		if path == "" {
+			path = "slackcmd.sqlite"
+		}
+		return sqlite.Open(path)
+	}
 
-	// interface reference for slack Commands
-	var cmd slack.Command
+	return nil, fmt.Errorf("unknown storage driver %q", driver)
+}
 
-	// Create FlagSet to store flags
-	fs := &slack.FlagSet{}
+func commandHandler(w http.ResponseWriter, r *http.Request) {
+	sc, err := slack.ParseSlashCommand(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Add commands here
-	switch sc.Command {
-	case "/fg":
-		cmd = &trello.Command{}
-		fs.Usage = "/fg help: FG Trello access"
-	case "/beats1":
-		cmd = &beats1.Command{}
-		fs.Usage = "/beats1 help: Song currently playing on Beats1"
-	case "/conference":
-		cmd = &calendar.Command{}
-		fs.Usage = "/conference help: Schedule for FG Conference room"
-	case "/qotd":
-		cmd = &qotd.Command{}
-		fs.Usage = "/qotd help: Sends the Question of the Day"
-	default:
+	// find the registered command
+	ci, ok := slack.Lookup(sc.Command)
+	if !ok {
 		err := errors.New("No Command found")
 		http.Error(w, err.Error(), http.StatusForbidden)
 		return
 	}
+	deps := depsFor(ci.ConfigSection())
+	start := time.Now()
+
+	// the correlation ID follows the command into async work and upstream calls
+	id := logging.NewID()
+	ctx := logging.WithID(r.Context(), id)
+	w.Header().Set(logging.Header, id)
+
+	// unsigned requests fall back to the command's legacy verification token
+	if !slack.IsVerified(r) && !ci.VerifyToken(deps.Settings, sc.Token) {
+		err := slack.WrapError(slack.Unauthorized, errors.New("invalid verification token"))
+		metrics.ObserveCommand(ci.Name(), outcome(err), time.Since(start))
+		writePayload(w, reportError(ctx, sc.Command, err))
+		return
+	}
 
-	fmt.Println("slash command:", sc.Text)
-
-	// parse out flags
-	parsedCommands, flags := slack.SeparateFlags(sc.Text)
-	sc.Text = parsedCommands
+	// commands missing required settings stay disabled until the config has them
+	if len(missingFor(ci.ConfigSection())) > 0 {
+		err := slack.ErrNotConfigured
+		metrics.ObserveCommand(ci.Name(), outcome(err), time.Since(start))
+		writePayload(w, reportError(ctx, sc.Command, err))
+		return
+	}
 
-	// command request returns payload
-	cp, err := cmd.Request(sc)
+	// commands that kept panicking stay off until an admin turns them back on
+	if isSuspended(ci.Name()) {
+		metrics.ObserveCommand(ci.Name(), metrics.Disabled, time.Since(start))
+		writePayload(w, reportError(ctx, sc.Command, errSuspended(sc.Command)))
+		return
+	}
 
-	if cp == nil {
-		err := errors.New("Unauthorized")
-		http.Error(w, err.Error(), http.StatusForbidden)
+	// keeps one user or channel from burning through the upstream quotas
+	if hit, wait := throttle(ci.Name(), slack.Trigger{TeamId: sc.TeamId, ChannelId: sc.ChannelId, UserId: sc.UserId}); hit != "" {
+		metrics.ObserveCommand(ci.Name(), metrics.Throttled, time.Since(start))
+		metrics.ThrottledRequests.WithLabelValues(ci.Name(), hit).Inc()
+		slog.InfoContext(ctx, "command throttled", "command", sc.Command, "limit", hit, "user", sc.UserId, "channel", sc.ChannelId, "wait", wait.String())
+		writePayload(w, throttled(wait))
 		return
 	}
 
+	// use the bot token of the workspace the command came from
+	sc.Installation, err = findInstallation(ctx, sc.EnterpriseId, sc.TeamId)
 	if err != nil {
-		err := errors.New("Unauthorized")
-		http.Error(w, err.Error(), http.StatusForbidden)
+		slog.ErrorContext(ctx, "installation lookup failed", "command", sc.Command, "team", sc.TeamId, "err", err)
+		http.Error(w, "Installation lookup failed", http.StatusInternalServerError)
 		return
 	}
+	if sc.Installation != nil {
+		sc.Client = installationClient(sc.Installation)
+	} else {
+		sc.Client = bot
+	}
+
+	c := ci.New(deps)
+	cmd := slack.WithContext(c)
+
+	// Create FlagSet to store flags
+	fs := &slack.FlagSet{}
+	fs.Usage = ci.UsageFor(sc.Command)
+
+	slog.DebugContext(ctx, "slash command", "command", sc.Command, "user", sc.UserId, "channel", sc.ChannelId, "text", sc.Text)
 
 	// Set Flags for Commands
+	var toChannel, private bool
 	slack.SetFlag(fs, "channel", "c", "Sends the response to the current channel", func() {
-		cp.Channel = fmt.Sprintf("#%v", sc.ChannelName)
-		cp.SendPayload = true
-		cp.SlashResponse = false
+		toChannel = true
 	})
 
 	slack.SetFlag(fs, "private", "p", "Sends a private message with the response", func() {
-		cp.SendPayload = true
-		cp.SlashResponse = false
+		private = true
 	})
 
-	sc.Text = parsedCommands
+	// commands declare their own flags alongside the global ones
+	if ci.Flags != nil {
+		ci.Flags(fs)
+	}
 
-	// TODO: Move ParseFlags call so it happen before cmd.Request is called
-	help, response := slack.ParseFlags(fs, flags)
+	// commands with subcommands add the flags for the subcommand typed
+	if fd, ok := c.(slack.FlagDefiner); ok {
+		fd.DefineFlags(fs, sc.Text)
+	}
+
+	// parse out flags before the command runs so help is returned right away
+	args, flags, err := fs.Separate(sc.Text)
+	if serr, ok := err.(*slack.SyntaxError); ok {
+		metrics.ObserveCommand(ci.Name(), metrics.Error, time.Since(start))
+		w.Write([]byte(fmt.Sprintf("%v: %v\n```%v```", sc.Command, serr, serr.Context(sc.Text))))
+		return
+	}
+	if err != nil {
+		metrics.ObserveCommand(ci.Name(), metrics.Error, time.Since(start))
+		w.Write([]byte(fmt.Sprintf("%v: %v\n%v", sc.Command, err, fs)))
+		return
+	}
+	sc.Text = strings.Join(args, " ")
+	sc.Args = args
+	sc.Flags = fs
+
+	// unknown or invalid flags are reported to the user instead of running
+	help, err := fs.Parse(flags)
+	if err != nil {
+		metrics.ObserveCommand(ci.Name(), metrics.Error, time.Since(start))
+		w.Write([]byte(fmt.Sprintf("%v: %v\n%v", sc.Command, err, fs)))
+		return
+	}
+	for i := range fs.Flags {
+		if fs.Flags[i].IsSet() {
+			metrics.FlagUses.WithLabelValues(ci.Name(), fs.Flags[i].Name).Inc()
+		}
+	}
 	if help == true {
-		cp.Text = response
+		metrics.ObserveCommand(ci.Name(), metrics.OK, time.Since(start))
+		w.Write([]byte(fmt.Sprint(fs)))
+		return
+	}
+
+	run := func(ctx context.Context) (*slack.CommandPayload, error) {
+		// every command gets a deadline so slow upstream calls are cancelled
+		ctx, cancel := context.WithTimeout(ctx, ci.RequestTimeout())
+		defer cancel()
+		ctx = slack.WithInstallation(ctx, sc.Installation)
+		ctx = slack.WithDeps(ctx, deps)
+
+		// command request returns payload
+		cp, err := cmd.RequestContext(ctx, sc)
+		if err != nil {
+			return nil, err
+		}
+		if cp == nil {
+			return nil, errors.New("command returned no payload")
+		}
+
+		// a modal replaces the message, nothing is sent to the channel
+		if cp.Modal != nil {
+			err := openModal(ctx, sc, cp.Modal)
+			if err != nil {
+				return nil, err
+			}
+			return &slack.CommandPayload{}, nil
+		}
+
+		// blocks Slack would reject are dropped so the text still gets through
+		if err := slack.ValidateBlocks(cp.Blocks, slack.MaxMessageBlocks); err != nil {
+			slog.WarnContext(ctx, "blocks dropped", "command", sc.Command, "err", err)
+			cp.Blocks = nil
+		}
+
+		if toChannel {
+			cp.Channel = fmt.Sprintf("#%v", sc.ChannelName)
+			cp.ResponseType = slack.ResponseInChannel
+			cp.SendPayload = true
+			cp.SlashResponse = false
+		}
+
+		if private {
+			cp.SendPayload = true
+			cp.SlashResponse = false
+		}
+
+		return cp, nil
+	}
+
+	// without a response_url the command has to answer before Slack times out
+	if sc.ResponseURL == "" {
+		cp, err := slack.Safely(func() (*slack.CommandPayload, error) { return run(ctx) })
+		trackPanics(ctx, ci.Name(), err, panicLimit)
+		metrics.ObserveCommand(ci.Name(), outcome(err), time.Since(start))
+		if err != nil {
+			// Slack only shows the reply of a 200
+			writePayload(w, reportError(ctx, sc.Command, err))
+			return
+		}
+
+		// check if the command wants to send a slash command response
+		if cp.SlashResponse && len(cp.Blocks) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(cp)
+		} else if cp.SlashResponse {
+			w.Write([]byte(cp.Text))
+		}
+
+		err = sendHook(ctx, sc, cp)
+		if err != nil {
+			metrics.DeliveryFailures.WithLabelValues(ci.Name()).Inc()
+			slog.ErrorContext(ctx, "hook delivery failed", "command", sc.Command, "err", err)
+		}
+		return
+	}
+
+	// acknowledge now and deliver the payload to the response_url when the
+	// command finishes. The Responder is shared with the command so it can
+	// post follow ups of its own.
+	sc.Responder = slack.NewResponder(sc.ResponseURL)
+	sc.Responder.Client = upstream
+	w.WriteHeader(http.StatusOK)
+
+	// tracked so shutdown waits for the reply to be delivered
+	slack.Go(func() {
+		// the request context ends with the acknowledgement
+		ctx := logging.WithID(context.Background(), id)
+		cp, err := slack.Safely(func() (*slack.CommandPayload, error) { return run(ctx) })
+		trackPanics(ctx, ci.Name(), err, panicLimit)
+		metrics.ObserveCommand(ci.Name(), outcome(err), time.Since(start))
+		if err != nil {
+			cp = reportError(ctx, sc.Command, err)
+		}
+
+		// a response_url that doesn't answer mustn't hold up shutdown
+		dctx, cancel := context.WithTimeout(ctx, deliverTimeout)
+		err = deliver(dctx, sc, cp)
+		cancel()
+		if err != nil {
+			metrics.DeliveryFailures.WithLabelValues(ci.Name()).Inc()
+			slog.ErrorContext(ctx, "reply delivery failed", "command", sc.Command, "err", err)
+		}
+	})
+}
+
+// outcome returns the metrics outcome of a command's error
+func outcome(err error) string {
+	var pe *slack.PanicError
+	switch {
+	case err == nil:
+		return metrics.OK
+	case errors.As(err, &pe):
+		return metrics.Panic
+	case errors.Is(err, context.DeadlineExceeded):
+		return metrics.Timeout
+	}
+
+	switch slack.KindOf(err) {
+	case slack.Unauthorized:
+		return metrics.Unauthorized
+	case slack.Misconfigured:
+		return metrics.NotConfigured
+	}
+	return metrics.Error
+}
+
+// reportError logs the cause of a command's error and returns the reply
+// telling the user about it with the request's ID
+func reportError(ctx context.Context, command string, err error) *slack.CommandPayload {
+	kind := slack.KindOf(err)
+	level := slog.LevelError
+	if kind == slack.BadInput || kind == slack.Misconfigured || kind == slack.Unauthorized {
+		level = slog.LevelWarn
+	}
+	args := []interface{}{"command", command, "kind", kind.String(), "err", err}
+	var pe *slack.PanicError
+	if errors.As(err, &pe) {
+		args = append(args, "stack", string(pe.Stack))
+	}
+	slog.Log(ctx, level, "command failed", args...)
+
+	return slack.ErrorPayload(command, err, logging.ID(ctx))
+}
+
+// writePayload answers the slash command with cp
+func writePayload(w http.ResponseWriter, cp *slack.CommandPayload) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cp)
+}
+
+// openModal opens the view with the slash command's trigger_id. Slack
+// expires trigger_ids after 3 seconds.
+func openModal(ctx context.Context, sc *slack.SlashCommand, v *slack.View) error {
+	if sc.Client == nil {
+		return errors.New("a bot token is required to open modals")
+	}
+
+	err := slack.ValidateBlocks(v.Blocks, slack.MaxModalBlocks)
+	if err != nil {
+		return err
+	}
+
+	_, err = sc.Client.OpenView(ctx, sc.TriggerId, v)
+	return err
+}
+
+// deliverTimeout limits how long posting a finished payload may take
+const deliverTimeout = 10 * time.Second
+
+// deliver sends a finished payload to the hook or response_url
+func deliver(ctx context.Context, sc *slack.SlashCommand, cp *slack.CommandPayload) error {
+	if sc.Hook != "" && cp.SendPayload {
+		return sendHook(ctx, sc, cp)
 	}
 
-	// check if the command wants to send a slash command response
-	if cp.SlashResponse {
-		w.Write([]byte(cp.Text))
+	if cp.SlashResponse || cp.SendPayload {
+		return sc.Responder.SendContext(ctx, cp)
 	}
 
+	return nil
+}
+
+// sendHook posts the payload to the hook URL passed with the command
+func sendHook(ctx context.Context, sc *slack.SlashCommand, cp *slack.CommandPayload) error {
 	// don't send payload if hook URL isn't passed
-	if sc.Hook != "" && cp.SendPayload == true {
-		cpJSON, err := json.Marshal(cp)
+	if sc.Hook == "" || cp.SendPayload == false {
+		return nil
+	}
+
+	return postHook(ctx, sc.Hook, cp)
+}
+
+// findInstallation returns the OAuth installation of a workspace or nil
+// when it has none
+func findInstallation(ctx context.Context, enterpriseID string, teamID string) (*slack.Installation, error) {
+	if installations == nil {
+		return nil, nil
+	}
+
+	i, err := installations.FindInstallation(ctx, enterpriseID, teamID)
+	if err == slack.ErrNoInstallation {
+		return nil, nil
+	}
+	return i, err
+}
+
+// installationClient returns the Web API client of an installation
+func installationClient(i *slack.Installation) *slack.Client {
+	c := i.Client()
+	c.HTTPClient = upstream
+	return c
+}
+
+// clientFor returns the Web API client for a workspace, falling back to
+// SLACK_BOT_TOKEN for workspaces without an installation
+func clientFor(ctx context.Context, enterpriseID string, teamID string) (*slack.Client, error) {
+	i, err := findInstallation(ctx, enterpriseID, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if i != nil {
+		return installationClient(i), nil
+	}
+
+	return bot, nil
+}
+
+// uninstall forgets the bot token of a workspace that removed the app
+func uninstall(ctx context.Context, req *slack.EventRequest) (*slack.CommandPayload, error) {
+	return nil, installations.DeleteInstallation(ctx, req.EnterpriseId, req.TeamId)
+}
+
+// revoke forgets the bot token of a workspace when it was revoked. Only
+// bot tokens are stored so revoked user tokens are ignored.
+func revoke(ctx context.Context, req *slack.EventRequest) (*slack.CommandPayload, error) {
+	if len(req.Event.Tokens.Bot) == 0 {
+		return nil, nil
+	}
+	return uninstall(ctx, req)
+}
+
+// replyEvent returns an EventReplier that posts to the channel of the event
+// with the workspace's bot token, or the incoming webhook without one
+func replyEvent(hook string) slack.EventReplier {
+	return func(ctx context.Context, req *slack.EventRequest, cp *slack.CommandPayload) error {
+		client, err := clientFor(ctx, req.EnterpriseId, req.TeamId)
 		if err != nil {
-			err := errors.New("Unauthorized")
-			http.Error(w, err.Error(), http.StatusForbidden)
-			return
+			return err
+		}
+		if cp.Unfurls != nil {
+			if client == nil {
+				return errors.New("no bot token to unfurl links with")
+			}
+			return client.Unfurl(ctx, req.Event.Channel, req.Event.MessageTs, cp.Unfurls)
+		}
+
+		ephemeral := cp.ResponseType == slack.ResponseEphemeral && req.Event.User != ""
+		if client != nil {
+			if ephemeral {
+				_, err := client.PostEphemeral(ctx, req.Event.Channel, req.Event.User, cp)
+				return err
+			}
+			_, _, err := client.PostMessage(ctx, req.Event.Channel, cp)
+			return err
 		}
 
-		cpJSONString := string(cpJSON[:])
+		if hook == "" {
+			return errors.New("no bot token or SLACK_WEBHOOK_URL to reply with")
+		}
+		if ephemeral {
+			// a webhook would show it to the whole channel
+			return errors.New("no bot token to reply to one user with")
+		}
+
+		cp.Channel = req.Event.Channel
+		return postHook(ctx, hook, cp)
+	}
+}
+
+// postHook posts the payload to an incoming webhook
+func postHook(ctx context.Context, hook string, cp *slack.CommandPayload) error {
+	cpJSON, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	cpJSONString := string(cpJSON[:])
+
+	// Make the request to the Slack API.
+	form := url.Values{"payload": {cpJSONString}}
+	req, err := http.NewRequestWithContext(ctx, "POST", hook, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := upstream.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
 
-		// Make the request to the Slack API.
-		http.PostForm(sc.Hook, url.Values{"payload": {cpJSONString}})
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("hook returned %v", res.Status)
 	}
 
+	return nil
 }
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jesselucas/slackcmd/config"
+	"github.com/jesselucas/slackcmd/logging"
+	"github.com/jesselucas/slackcmd/metrics"
+	"github.com/jesselucas/slackcmd/slack"
+	"github.com/jesselucas/slackcmd/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// testCommand answers with its text or fails with err
+type testCommand struct {
+	err error
+}
+
+func (c testCommand) Request(sc *slack.SlashCommand) (*slack.CommandPayload, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &slack.CommandPayload{Text: sc.Text, SlashResponse: true}, nil
+}
+
+func init() {
+	for name, err := range map[string]error{
+		"/testok":       nil,
+		"/testfail":     errors.New("boom"),
+		"/testupstream": slack.WrapError(slack.Upstream, errors.New("trello: 503")),
+		"/testinput":    slack.Errorf(slack.BadInput, "There's no board named %q.", "Dev"),
+	} {
+		cmd := testCommand{err}
+		slack.Register(slack.CommandInfo{
+			Names:    []string{name},
+			TokenEnv: "SLACK_KEY_" + strings.ToUpper(name[1:]),
+			New:      func(slack.Deps) slack.Command { return cmd },
+		})
+	}
+}
+
+// contextCommand answers with the request ID in its ctx, or waits for ctx
+// to end when slow
+type contextCommand struct {
+	slow bool
+}
+
+func (c contextCommand) Request(sc *slack.SlashCommand) (*slack.CommandPayload, error) {
+	return nil, errors.New("Request called instead of RequestContext")
+}
+
+func (c contextCommand) RequestContext(ctx context.Context, sc *slack.SlashCommand) (*slack.CommandPayload, error) {
+	if c.slow {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &slack.CommandPayload{Text: logging.ID(ctx), SlashResponse: true}, nil
+}
+
+// flagsCommand answers with the value of its --board flag
+type flagsCommand struct{}
+
+func (c flagsCommand) Request(sc *slack.SlashCommand) (*slack.CommandPayload, error) {
+	return &slack.CommandPayload{Text: "board=" + sc.Flags.Value("board"), SlashResponse: true}, nil
+}
+
+func init() {
+	slack.Register(slack.CommandInfo{
+		Names:    []string{"/testctx"},
+		TokenEnv: "SLACK_KEY_TESTCTX",
+		New:      func(slack.Deps) slack.Command { return contextCommand{} },
+	})
+	slack.Register(slack.CommandInfo{
+		Names:    []string{"/testflags"},
+		TokenEnv: "SLACK_KEY_TESTFLAGS",
+		Flags: func(fs *slack.FlagSet) {
+			slack.SetStringFlag(fs, "board", "b", "Board to show", "Wiki")
+		},
+		New: func(slack.Deps) slack.Command { return flagsCommand{} },
+	})
+	slack.Register(slack.CommandInfo{
+		Names:    []string{"/testslow"},
+		TokenEnv: "SLACK_KEY_TESTSLOW",
+		Timeout:  50 * time.Millisecond,
+		New:      func(slack.Deps) slack.Command { return contextCommand{slow: true} },
+	})
+}
+
+func TestMain(m *testing.M) {
+	store = storage.NewMemory()
+
+	// every test command is verified with the token "t"
+	cfg := &config.Config{Commands: make(map[string]config.CommandSettings)}
+	for _, ci := range slack.Commands() {
+		cfg.Commands[ci.ConfigSection()] = config.CommandSettings{"verification_token": "t"}
+	}
+	applySettings(cfg)
+
+	os.Exit(m.Run())
+}
+
+// slackServer receives what the server posts to Slack. upstream sends
+// every request to it, so response_urls keep their Slack hosts.
+type slackServer struct {
+	mu       sync.Mutex
+	received []slack.CommandPayload
+}
+
+func newSlackServer(t *testing.T) *slackServer {
+	s := &slackServer{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cp slack.CommandPayload
+		json.NewDecoder(r.Body).Decode(&cp)
+		s.mu.Lock()
+		s.received = append(s.received, cp)
+		s.mu.Unlock()
+	}))
+	target, _ := url.Parse(ts.URL)
+
+	previous := upstream
+	upstream = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.URL.Scheme, r.URL.Host = target.Scheme, target.Host
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	t.Cleanup(func() {
+		upstream = previous
+		ts.Close()
+	})
+	return s
+}
+
+func (s *slackServer) payloads() []slack.CommandPayload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]slack.CommandPayload(nil), s.received...)
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// runCommand sends a slash command to commandHandler and waits for the
+// replies it delivers in the background
+func runCommand(t *testing.T, v url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/cmd", strings.NewReader(v.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	commandHandler(w, r)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := slack.Wait(ctx); err != nil {
+		t.Fatal("Test errored. Background work didn't finish:", err)
+	}
+	return w
+}
+
+func TestCommandHandlerResponseURL(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{"/testok", "hello"},
+		{"/testfail", "Sorry, /testfail was unable to complete your request."},
+	}
+
+	for _, test := range tests {
+		slackAPI := newSlackServer(t)
+		w := runCommand(t, url.Values{
+			"command":      {test.command},
+			"text":         {"hello"},
+			"token":        {"t"},
+			"response_url": {"https://hooks.slack.com/commands/T1/1/abc"},
+		})
+
+		if w.Code != http.StatusOK || w.Body.Len() != 0 {
+			t.Errorf("Test errored. %v should be acknowledged with an empty 200 but got %v %q", test.command, w.Code, w.Body.String())
+		}
+		replies := slackAPI.payloads()
+		if len(replies) != 1 || !strings.HasPrefix(replies[0].Text, test.expected) {
+			t.Errorf("Test errored. %v should reply %q but replied %+v", test.command, test.expected, replies)
+		}
+	}
+}
+
+func TestCommandHandlerErrors(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{"/testfail", "Sorry, /testfail was unable to complete your request."},
+		{"/testupstream", "Sorry, /testupstream couldn't reach a service it depends on. Try again in a few minutes."},
+		{"/testinput", "There's no board named \"Dev\"."},
+	}
+
+	for _, test := range tests {
+		for _, responseURL := range []string{"", "https://hooks.slack.com/commands/T1/1/abc"} {
+			slackAPI := newSlackServer(t)
+			w := runCommand(t, url.Values{
+				"command":      {test.command},
+				"token":        {"t"},
+				"response_url": {responseURL},
+			})
+
+			// without a response_url the reply is the answer to the request
+			var reply slack.CommandPayload
+			if responseURL == "" {
+				json.NewDecoder(w.Body).Decode(&reply)
+			} else if replies := slackAPI.payloads(); len(replies) == 1 {
+				reply = replies[0]
+			}
+
+			expected := test.expected + " (ref `" + w.Header().Get("X-Request-Id") + "`)"
+			if w.Code != http.StatusOK || reply.Text != expected || reply.ResponseType != slack.ResponseEphemeral {
+				t.Errorf("Test errored. %v with response_url %q should reply %q but got %v %q", test.command, responseURL, expected, w.Code, reply.Text)
+			}
+		}
+	}
+}
+
+func TestCommandHandlerContext(t *testing.T) {
+	tests := []struct {
+		command string
+		id      bool // reply is the request ID
+	}{
+		{"/testok", false}, // only implements Request
+		{"/testctx", true},
+	}
+
+	for _, test := range tests {
+		w := runCommand(t, url.Values{
+			"command": {test.command},
+			"text":    {"hello"},
+			"token":   {"t"},
+		})
+
+		expected := "hello"
+		if test.id {
+			expected = w.Header().Get("X-Request-Id")
+		}
+		if w.Code != http.StatusOK || expected == "" || w.Body.String() != expected {
+			t.Errorf("Test errored. %v should reply %q but got %v %q", test.command, expected, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestCommandHandlerFlags(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+		exact    bool // or the start of the reply
+	}{
+		{"", "board=Wiki", true},
+		{"-b Design", "board=Design", true},
+		{"--board=Design", "board=Design", true},
+		// unknown flags answer with the usage, which lists the command's flags
+		{"--nope", "/testflags: unknown flag --nope\n", false},
+	}
+
+	// text that can't be split into words is reported and counted as an error
+	before := testutil.ToFloat64(metrics.Requests.WithLabelValues("/testflags", metrics.Error))
+	w := runCommand(t, url.Values{"command": {"/testflags"}, "text": {`-b "Design`}, "token": {"t"}})
+	if !strings.Contains(w.Body.String(), "unterminated quote") {
+		t.Errorf("Test errored. Unterminated quote should be reported but replied %q", w.Body.String())
+	}
+	if n := testutil.ToFloat64(metrics.Requests.WithLabelValues("/testflags", metrics.Error)) - before; n != 1 {
+		t.Errorf("Test errored. Errors of an unterminated quote should be %v but are %v", 1, n)
+	}
+
+	for _, test := range tests {
+		w := runCommand(t, url.Values{
+			"command": {"/testflags"},
+			"text":    {test.text},
+			"token":   {"t"},
+		})
+
+		body := w.Body.String()
+		if test.exact && body != test.expected {
+			t.Errorf("Test errored. %q should reply %q but replied %q", test.text, test.expected, body)
+		}
+		if !test.exact && (!strings.HasPrefix(body, test.expected) || !strings.Contains(body, "--board (-b) <string>: Board to show")) {
+			t.Errorf("Test errored. %q should reply %q and the usage but replied %q", test.text, test.expected, body)
+		}
+	}
+}
+
+func TestCommandHandlerTimeout(t *testing.T) {
+	expected := "Sorry, /testslow couldn't reach a service it depends on. Try again in a few minutes."
+
+	for _, responseURL := range []string{"", "https://hooks.slack.com/commands/T1/1/abc"} {
+		slackAPI := newSlackServer(t)
+		before := testutil.ToFloat64(metrics.Requests.WithLabelValues("/testslow", metrics.Timeout))
+		start := time.Now()
+		w := runCommand(t, url.Values{
+			"command":      {"/testslow"},
+			"token":        {"t"},
+			"response_url": {responseURL},
+		})
+
+		// cancelled at the command's timeout, not DefaultTimeout
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Test errored. /testslow with response_url %q should be cancelled after 50ms but took %v", responseURL, elapsed)
+		}
+
+		var reply slack.CommandPayload
+		if responseURL == "" {
+			json.NewDecoder(w.Body).Decode(&reply)
+		} else if replies := slackAPI.payloads(); len(replies) == 1 {
+			reply = replies[0]
+		}
+		if !strings.HasPrefix(reply.Text, expected) {
+			t.Errorf("Test errored. /testslow with response_url %q should reply %q but replied %q", responseURL, expected, reply.Text)
+		}
+		if n := testutil.ToFloat64(metrics.Requests.WithLabelValues("/testslow", metrics.Timeout)) - before; n != 1 {
+			t.Errorf("Test errored. /testslow with response_url %q timeouts should be %v but are %v", responseURL, 1, n)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	previous := limits
+	defer func() { limits = previous }()
+	limits = newLimits(config.RateLimit{
+		User:    config.Rate{Requests: 1, Per: config.Duration{Duration: time.Minute}, Burst: 1},
+		Channel: config.Rate{Requests: 3, Per: config.Duration{Duration: time.Minute}, Burst: 3},
+	})
+
+	spammer := slack.Trigger{TeamId: "T1", ChannelId: "C1", UserId: "U1"}
+	if hit, _ := throttle("/fg", spammer); hit != "" {
+		t.Fatalf("Test errored. The first request should be allowed but hit the %v limit", hit)
+	}
+
+	// retries of a throttled user don't spend the channel's tokens
+	for i := 0; i < 10; i++ {
+		hit, wait := throttle("/fg", spammer)
+		if hit != "user" || wait <= 0 || wait > time.Minute {
+			t.Fatalf("Test errored. Retry %v should hit the user limit but hit %q waiting %v", i, hit, wait)
+		}
+	}
+	for _, user := range []string{"U2", "U3"} {
+		if hit, _ := throttle("/fg", slack.Trigger{TeamId: "T1", ChannelId: "C1", UserId: user}); hit != "" {
+			t.Errorf("Test errored. %v should be allowed but hit the %v limit", user, hit)
+		}
+	}
+	if hit, _ := throttle("/fg", slack.Trigger{TeamId: "T1", ChannelId: "C1", UserId: "U4"}); hit != "channel" {
+		t.Errorf("Test errored. U4 should hit the channel limit but hit %q", hit)
+	}
+
+	// every command has its own buckets
+	if hit, _ := throttle("/qotd", spammer); hit != "" {
+		t.Errorf("Test errored. /qotd should be allowed but hit the %v limit", hit)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	previous := installations
+	defer func() { installations = previous }()
+
+	tests := []struct {
+		tokens    slack.RevokedTokens
+		installed bool
+	}{
+		{slack.RevokedTokens{OAuth: []string{"U1"}}, true}, // user tokens aren't stored
+		{slack.RevokedTokens{Bot: []string{"UB"}}, false},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		installations = slack.NewMemoryInstallationStore()
+		installations.SaveInstallation(ctx, &slack.Installation{EnterpriseId: "E1", IsEnterpriseInstall: true, BotToken: "xoxb-org"})
+
+		req := &slack.EventRequest{EnterpriseId: "E1", TeamId: "T1", Event: slack.Event{Type: "tokens_revoked", Tokens: test.tokens}}
+		if _, err := revoke(ctx, req); err != nil {
+			t.Fatal("Test errored. revoke returned", err)
+		}
+		_, err := installations.FindInstallation(ctx, "E1", "T1")
+		if installed := err == nil; installed != test.installed {
+			t.Errorf("Test errored. After revoking %+v installed should be %v but is %v", test.tokens, test.installed, installed)
+		}
+	}
+}
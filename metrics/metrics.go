@@ -0,0 +1,171 @@
+// Package metrics exposes Prometheus metrics for command traffic and the
+// upstream services commands call
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Outcomes of a command request
+const (
+	OK            = "ok"
+	Unauthorized  = "unauthorized"
+	NotConfigured = "not_configured"
+	Error         = "error"
+	Timeout       = "timeout"
+	Panic         = "panic"
+	Disabled      = "disabled" // turned off after repeated panics
+	Throttled     = "throttled"
+)
+
+var (
+	// Requests counts slash commands by command and outcome
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slackcmd_command_requests_total",
+		Help: "Slash command requests by command and outcome.",
+	}, []string{"command", "outcome"})
+
+	// Duration is how long commands take to build their payload
+	Duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "slackcmd_command_duration_seconds",
+		Help:    "Time commands take to answer.",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"command"})
+
+	// UpstreamRequests counts calls to Trello, Google, Twitter, Slack and
+	// other services by provider and HTTP status
+	UpstreamRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slackcmd_upstream_requests_total",
+		Help: "Outbound HTTP requests by provider and status, \"error\" when no response was received.",
+	}, []string{"provider", "status"})
+
+	// UpstreamDuration is the latency of outbound calls by provider
+	UpstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "slackcmd_upstream_duration_seconds",
+		Help:    "Latency of outbound HTTP requests by provider.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider"})
+
+	// FlagUses counts the flags passed to each command
+	FlagUses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slackcmd_flag_uses_total",
+		Help: "Flags passed to commands.",
+	}, []string{"command", "flag"})
+
+	// DeliveryFailures counts payloads that couldn't be posted to the
+	// response_url or webhook
+	DeliveryFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slackcmd_delivery_failures_total",
+		Help: "Payloads that failed to reach the response_url or webhook.",
+	}, []string{"command"})
+
+	// ThrottledRequests counts requests turned away by the rate limit by
+	// command and the limit hit: user, channel, team or command
+	ThrottledRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slackcmd_throttled_requests_total",
+		Help: "Slash command requests turned away by the rate limit.",
+	}, []string{"command", "limit"})
+)
+
+var registry = prometheus.NewRegistry()
+
+func init() {
+	registry.MustRegister(
+		Requests,
+		Duration,
+		UpstreamRequests,
+		UpstreamDuration,
+		FlagUses,
+		DeliveryFailures,
+		ThrottledRequests,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Register adds collectors of other packages to the /metrics output
+func Register(cs ...prometheus.Collector) {
+	registry.MustRegister(cs...)
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveCommand records a command request that took d
+func ObserveCommand(command string, outcome string, d time.Duration) {
+	Requests.WithLabelValues(command, outcome).Inc()
+	Duration.WithLabelValues(command).Observe(d.Seconds())
+}
+
+// providers names the services commands call by host suffix
+var providers = []struct {
+	suffix   string
+	provider string
+}{
+	{"trello.com", "trello"},
+	{"googleapis.com", "google"},
+	{"google.com", "google"},
+	{"twitter.com", "twitter"},
+	{"slack.com", "slack"},
+}
+
+// Provider returns the provider label of a host. Unknown hosts are
+// labelled "other" to keep the number of series bounded.
+func Provider(host string) string {
+	host = strings.ToLower(host)
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+
+	for _, p := range providers {
+		if host == p.suffix || strings.HasSuffix(host, "."+p.suffix) {
+			return p.provider
+		}
+	}
+	return "other"
+}
+
+// transport records the status and latency of requests to UpstreamRequests
+// and UpstreamDuration
+type transport struct {
+	base http.RoundTripper
+}
+
+// Transport wraps base, or http.DefaultTransport when it is nil, to record
+// upstream metrics
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	provider := Provider(req.URL.Host)
+	start := time.Now()
+
+	res, err := t.base.RoundTrip(req)
+
+	UpstreamDuration.WithLabelValues(provider).Observe(time.Since(start).Seconds())
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(res.StatusCode)
+	}
+	UpstreamRequests.WithLabelValues(provider, status).Inc()
+
+	return res, err
+}
+
+// Client returns an http.Client recording upstream metrics
+func Client(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: Transport(nil), Timeout: timeout}
+}
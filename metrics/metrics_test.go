@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestProvider(t *testing.T) {
+	tests := []struct {
+		host     string
+		provider string
+	}{
+		{"api.trello.com", "trello"},
+		{"www.googleapis.com", "google"},
+		{"accounts.google.com:443", "google"},
+		{"api.twitter.com", "twitter"},
+		{"hooks.slack.com", "slack"},
+		{"notslack.com", "other"},
+		{"127.0.0.1:8080", "other"},
+	}
+
+	for _, test := range tests {
+		if p := Provider(test.host); p != test.provider {
+			t.Errorf("Test errored. Provider of %v should be %v but is %v", test.host, test.provider, p)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer ts.Close()
+
+	before := testutil.ToFloat64(UpstreamRequests.WithLabelValues("other", "418"))
+
+	res, err := Client(time.Second).Get(ts.URL)
+	if err != nil {
+		t.Fatal("Test errored. Get returned", err)
+	}
+	res.Body.Close()
+
+	if n := testutil.ToFloat64(UpstreamRequests.WithLabelValues("other", "418")) - before; n != 1 {
+		t.Errorf("Test errored. Upstream requests should grow by 1 but grew by %v", n)
+	}
+
+	// requests that never get a response are counted as errors
+	before = testutil.ToFloat64(UpstreamRequests.WithLabelValues("other", "error"))
+	ts.Close()
+	Client(time.Second).Get(ts.URL)
+	if n := testutil.ToFloat64(UpstreamRequests.WithLabelValues("other", "error")) - before; n != 1 {
+		t.Errorf("Test errored. Upstream errors should grow by 1 but grew by %v", n)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	before := testutil.ToFloat64(Requests.WithLabelValues("/test", OK))
+	ObserveCommand("/test", OK, 10*time.Millisecond)
+	if n := testutil.ToFloat64(Requests.WithLabelValues("/test", OK)) - before; n != 1 {
+		t.Errorf("Test errored. Command requests should grow by 1 but grew by %v", n)
+	}
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := w.Body.String()
+	if !strings.Contains(body, "slackcmd_command_requests_total") {
+		t.Errorf("Test errored. Metrics should include the command requests but are\n%v", body)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/jesselucas/slackcmd/metrics"
+	"github.com/jesselucas/slackcmd/slack"
+)
+
+// panicLimit is the panics in a row that turn a command off, 0 for never
+var panicLimit int
+
+var (
+	panicsMu sync.Mutex
+	// panics counts the panics in a row of each command by name
+	panics = make(map[string]int)
+	// suspended holds the commands turned off for panicking too often
+	suspended = make(map[string]bool)
+)
+
+// trackPanics counts a command's panics and turns it off when it reaches
+// limit panics in a row. Any other result starts the count over.
+func trackPanics(ctx context.Context, name string, err error, limit int) {
+	panicsMu.Lock()
+	defer panicsMu.Unlock()
+
+	var pe *slack.PanicError
+	if !errors.As(err, &pe) {
+		delete(panics, name)
+		return
+	}
+
+	panics[name]++
+	if limit > 0 && panics[name] >= limit && !suspended[name] {
+		suspended[name] = true
+		slog.ErrorContext(ctx, "command turned off after repeated panics", "command", name, "panics", panics[name])
+	}
+}
+
+// isSuspended reports whether a command was turned off for panicking
+func isSuspended(name string) bool {
+	panicsMu.Lock()
+	defer panicsMu.Unlock()
+	return suspended[name]
+}
+
+// resume turns a suspended command back on
+func resume(name string) bool {
+	panicsMu.Lock()
+	defer panicsMu.Unlock()
+
+	if !suspended[name] {
+		return false
+	}
+	delete(suspended, name)
+	delete(panics, name)
+	return true
+}
+
+// errSuspended is the error of a command turned off for panicking
+func errSuspended(command string) error {
+	return slack.Errorf(slack.Internal, "Sorry, %v has been turned off after failing repeatedly. Ask an admin to turn it back on.", command)
+}
+
+// guard runs the handlers a command registered for interactions and events
+// the way its slash command runs: not while it's turned off, within its rate
+// limits, and counting their panics as the command's
+func guard(ctx context.Context, section string, t slack.Trigger, run func(ctx context.Context) (*slack.CommandPayload, error)) (*slack.CommandPayload, error) {
+	ci, ok := slack.LookupSection(section)
+	if !ok {
+		return run(ctx)
+	}
+
+	if isSuspended(ci.Name()) {
+		return nil, errSuspended(ci.Name())
+	}
+
+	if hit, wait := throttle(ci.Name(), t); hit != "" {
+		metrics.ThrottledRequests.WithLabelValues(ci.Name(), hit).Inc()
+		slog.InfoContext(ctx, "handler throttled", "command", ci.Name(), "limit", hit, "user", t.UserId, "channel", t.ChannelId, "wait", wait.String())
+		return nil, errThrottled(wait)
+	}
+
+	cp, err := run(ctx)
+	trackPanics(ctx, ci.Name(), err, panicLimit)
+	return cp, err
+}
+
+// enableHandler turns a suspended command back on, Ex.
+// POST /admin/enable?command=/fg with an Authorization: Bearer <admin_token>
+// header. It's only served when an admin token is set.
+func enableHandler(adminToken string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ci, ok := slack.Lookup(r.FormValue("command"))
+		if !ok {
+			http.Error(w, "No Command found", http.StatusNotFound)
+			return
+		}
+
+		if resume(ci.Name()) {
+			slog.InfoContext(r.Context(), "command turned back on", "command", ci.Name())
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"command": ci.Name(), "status": "enabled"})
+	})
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jesselucas/slackcmd/config"
+	"github.com/jesselucas/slackcmd/slack"
+)
+
+// resetPanics starts the test with no panics counted and clears the ones
+// it counted when it's done
+func resetPanics(t *testing.T) {
+	reset := func() {
+		panicsMu.Lock()
+		defer panicsMu.Unlock()
+		panics = make(map[string]int)
+		suspended = make(map[string]bool)
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestTrackPanics(t *testing.T) {
+	panicked := &slack.PanicError{Value: "boom"}
+	failed := errors.New("boom")
+
+	tests := []struct {
+		errs      []error
+		limit     int
+		suspended bool
+	}{
+		{[]error{panicked, panicked}, 3, false},
+		{[]error{panicked, panicked, panicked}, 3, true},
+		{[]error{panicked, panicked, nil, panicked}, 3, false}, // success starts over
+		{[]error{panicked, failed, panicked}, 2, false},        // so do other errors
+		{[]error{panicked, panicked, panicked}, 0, false},      // never turned off
+		{[]error{slack.WrapError(slack.Upstream, panicked)}, 1, true},
+	}
+
+	for i, test := range tests {
+		resetPanics(t)
+		for _, err := range test.errs {
+			trackPanics(context.Background(), "/testok", err, test.limit)
+		}
+		if s := isSuspended("/testok"); s != test.suspended {
+			t.Errorf("Test errored. Case %v suspended should be %v but is %v", i, test.suspended, s)
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	resetPanics(t)
+	if resume("/testok") {
+		t.Error("Test errored. Resuming a command that's on should be false")
+	}
+
+	trackPanics(context.Background(), "/testok", &slack.PanicError{Value: "boom"}, 1)
+	if !resume("/testok") {
+		t.Error("Test errored. Resuming a suspended command should be true")
+	}
+	if isSuspended("/testok") {
+		t.Error("Test errored. Resumed command should be on")
+	}
+
+	// the count starts over
+	trackPanics(context.Background(), "/testok", &slack.PanicError{Value: "boom"}, 2)
+	if isSuspended("/testok") {
+		t.Error("Test errored. One panic after resuming shouldn't turn the command off")
+	}
+}
+
+func TestEnableHandler(t *testing.T) {
+	tests := []struct {
+		method  string
+		token   string
+		command string
+		status  int
+		resumed bool
+	}{
+		{"POST", "admin", "/testok", http.StatusOK, true},
+		{"GET", "admin", "/testok", http.StatusMethodNotAllowed, false},
+		{"POST", "wrong", "/testok", http.StatusUnauthorized, false},
+		{"POST", "", "/testok", http.StatusUnauthorized, false},
+		{"POST", "admin", "/nope", http.StatusNotFound, false},
+	}
+
+	h := enableHandler("admin")
+	for _, test := range tests {
+		resetPanics(t)
+		trackPanics(context.Background(), "/testok", &slack.PanicError{Value: "boom"}, 1)
+
+		r := httptest.NewRequest(test.method, "/admin/enable", strings.NewReader("command="+test.command))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Errorf("Test errored. %v %v with %q status should be %v but is %v", test.method, test.command, test.token, test.status, w.Code)
+		}
+		if resumed := !isSuspended("/testok"); resumed != test.resumed {
+			t.Errorf("Test errored. %v %v with %q resumed should be %v but is %v", test.method, test.command, test.token, test.resumed, resumed)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	resetPanics(t)
+	defer func(limit int) { panicLimit = limit }(panicLimit)
+	panicLimit = 2
+
+	var ran int
+	run := func(ctx context.Context) (*slack.CommandPayload, error) {
+		ran++
+		return nil, &slack.PanicError{Value: "boom"}
+	}
+	tr := slack.Trigger{TeamId: "T1", ChannelId: "C1", UserId: "U1"}
+
+	tests := []struct {
+		section   string
+		ran       int
+		suspended bool
+	}{
+		{"testok", 1, false},
+		{"testok", 2, true}, // panics of interactions and events count
+		{"testok", 2, true}, // and they don't run while the command is off
+		{"", 3, true},       // handlers of no command always run
+	}
+
+	for i, test := range tests {
+		_, err := guard(context.Background(), test.section, tr, run)
+		if ran != test.ran {
+			t.Errorf("Test errored. Case %v runs should be %v but are %v", i, test.ran, ran)
+		}
+		if s := isSuspended("/testok"); s != test.suspended {
+			t.Errorf("Test errored. Case %v suspended should be %v but is %v", i, test.suspended, s)
+		}
+		if err == nil {
+			t.Errorf("Test errored. Case %v should fail", i)
+		}
+	}
+}
+
+func TestGuardThrottle(t *testing.T) {
+	resetPanics(t)
+	previous := limits
+	defer func() { limits = previous }()
+	limits = newLimits(config.RateLimit{
+		User: config.Rate{Requests: 1, Per: config.Duration{Duration: time.Minute}, Burst: 1},
+	})
+
+	var ran int
+	run := func(ctx context.Context) (*slack.CommandPayload, error) {
+		ran++
+		return nil, nil
+	}
+
+	tests := []struct {
+		section string
+		user    string
+		ran     int
+		err     string
+	}{
+		{"testok", "U1", 1, ""},
+		{"testok", "U1", 1, "Slow down, try again in 60s."},
+		{"testok", "U2", 2, ""},
+		{"testfail", "U1", 3, ""}, // every command has its own buckets
+	}
+
+	for i, test := range tests {
+		_, err := guard(context.Background(), test.section, slack.Trigger{TeamId: "T1", ChannelId: "C1", UserId: test.user}, run)
+		if ran != test.ran {
+			t.Errorf("Test errored. Case %v runs should be %v but are %v", i, test.ran, ran)
+		}
+		var msg string
+		if err != nil {
+			msg = slack.ErrorPayload("", err, "").Text
+		}
+		if msg != test.err {
+			t.Errorf("Test errored. Case %v error should be %q but is %q", i, test.err, msg)
+		}
+	}
+}
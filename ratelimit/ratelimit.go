@@ -0,0 +1,129 @@
+// Package ratelimit limits how often a key, Ex. a user running a command,
+// is let through with a token bucket per key
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limit is how many requests a key may make. Buckets hold up to Burst
+// tokens and gain one every Every.
+type Limit struct {
+	Every time.Duration
+	Burst int
+}
+
+// Per returns the Limit of n requests per interval with burst, Ex.
+// Per(10, time.Minute, 3)
+func Per(n int, interval time.Duration, burst int) Limit {
+	if n <= 0 {
+		return Limit{}
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return Limit{Every: interval / time.Duration(n), Burst: burst}
+}
+
+// Zero reports whether l doesn't limit anything
+func (l Limit) Zero() bool {
+	return l.Every <= 0
+}
+
+// sweepEvery is the calls to Allow between dropping the full buckets
+const sweepEvery = 1024
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket for every key. The zero Limit lets everything
+// through.
+type Limiter struct {
+	limit Limit
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+}
+
+// New returns a Limiter applying limit to every key
+func New(limit Limit) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from key's bucket. When the bucket is empty it
+// returns false and how long until it has a token again.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.limit.Zero() {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.calls++
+	if l.calls%sweepEvery == 0 {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.refill(now, l.limit)
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) * float64(l.limit.Every))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Refund gives back the token Allow took from key's bucket, Ex. when
+// another limit turned the request away
+func (l *Limiter) Refund(key string) {
+	if l.limit.Zero() {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return
+	}
+	b.refill(l.now(), l.limit)
+	if b.tokens++; b.tokens > float64(l.limit.Burst) {
+		b.tokens = float64(l.limit.Burst)
+	}
+}
+
+func (b *bucket) refill(now time.Time, limit Limit) {
+	b.tokens += float64(now.Sub(b.last)) / float64(limit.Every)
+	if max := float64(limit.Burst); b.tokens > max {
+		b.tokens = max
+	}
+	b.last = now
+}
+
+// sweep drops the buckets that have refilled, they're the same as new ones
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		b.refill(now, l.limit)
+		if b.tokens >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPer(t *testing.T) {
+	tests := []struct {
+		n        int
+		interval time.Duration
+		burst    int
+		expected Limit
+	}{
+		{10, time.Minute, 3, Limit{Every: 6 * time.Second, Burst: 3}},
+		{1, time.Second, 0, Limit{Every: time.Second, Burst: 1}},
+		{0, time.Minute, 5, Limit{}},
+	}
+
+	for _, test := range tests {
+		if l := Per(test.n, test.interval, test.burst); l != test.expected {
+			t.Errorf("Test errored. Per(%v, %v, %v) should be %+v but is %+v", test.n, test.interval, test.burst, test.expected, l)
+		}
+	}
+}
+
+func TestAllow(t *testing.T) {
+	now := time.Date(2016, 3, 16, 0, 0, 0, 0, time.UTC)
+	l := New(Per(1, 10*time.Second, 2))
+	l.now = func() time.Time { return now }
+
+	tests := []struct {
+		key     string
+		advance time.Duration
+		allowed bool
+		wait    time.Duration
+	}{
+		{"U1", 0, true, 0},
+		{"U1", 0, true, 0}, // burst of 2
+		{"U1", 0, false, 10 * time.Second},
+		{"U2", 0, true, 0}, // other keys have their own bucket
+		{"U1", 4 * time.Second, false, 6 * time.Second},
+		{"U1", 6 * time.Second, true, 0},
+		{"U1", time.Minute, true, 0}, // refills up to the burst only
+		{"U1", 0, true, 0},
+		{"U1", 0, false, 10 * time.Second},
+	}
+
+	for i, test := range tests {
+		now = now.Add(test.advance)
+		allowed, wait := l.Allow(test.key)
+		if allowed != test.allowed || wait != test.wait {
+			t.Errorf("Test errored. Call %v for %v should be %v, %v but is %v, %v", i, test.key, test.allowed, test.wait, allowed, wait)
+		}
+	}
+}
+
+func TestAllowZero(t *testing.T) {
+	l := New(Limit{})
+	for i := 0; i < 100; i++ {
+		if ok, _ := l.Allow("U1"); !ok {
+			t.Fatal("Test errored. The zero Limit should allow every call")
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	now := time.Date(2016, 3, 16, 0, 0, 0, 0, time.UTC)
+	l := New(Per(1, time.Second, 1))
+	l.now = func() time.Time { return now }
+
+	l.Allow("U1")
+	now = now.Add(time.Minute)
+	for i := 1; i < sweepEvery; i++ {
+		l.Allow("U2")
+	}
+
+	if _, ok := l.buckets["U1"]; ok {
+		t.Error("Test errored. U1's refilled bucket should have been dropped")
+	}
+	if _, ok := l.buckets["U2"]; !ok {
+		t.Error("Test errored. U2's empty bucket should be kept")
+	}
+}
+
+func TestRefund(t *testing.T) {
+	now := time.Date(2016, 3, 16, 0, 0, 0, 0, time.UTC)
+	l := New(Per(1, time.Minute, 2))
+	l.now = func() time.Time { return now }
+
+	l.Allow("U1")
+	l.Allow("U1")
+	l.Refund("U1")
+	if ok, _ := l.Allow("U1"); !ok {
+		t.Error("Test errored. The refunded token should be allowed")
+	}
+	if ok, _ := l.Allow("U1"); ok {
+		t.Error("Test errored. The bucket should be empty again")
+	}
+
+	// refunds don't grow the bucket past its burst
+	l.Refund("U2")
+	l.Allow("U3")
+	l.Refund("U3")
+	l.Refund("U3")
+	for i, expected := range []bool{true, true, false} {
+		if ok, _ := l.Allow("U3"); ok != expected {
+			t.Errorf("Test errored. Call %v should be %v but is %v", i, expected, ok)
+		}
+	}
+}
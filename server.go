@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/jesselucas/slackcmd/config"
+	"github.com/jesselucas/slackcmd/slack"
+)
+
+// newServer returns the http.Server for h with the timeouts, body limit and
+// TLS of the server config
+func newServer(c config.Server, h http.Handler) (*http.Server, error) {
+	srv := &http.Server{
+		Addr:              c.Addr,
+		Handler:           http.MaxBytesHandler(h, c.MaxBodyBytes),
+		ReadTimeout:       c.ReadTimeout.Duration,
+		ReadHeaderTimeout: c.ReadTimeout.Duration,
+		WriteTimeout:      c.WriteTimeout.Duration,
+		IdleTimeout:       c.IdleTimeout.Duration,
+	}
+
+	switch {
+	case c.AutocertHosts != "":
+		if c.TLSCert != "" {
+			return nil, errors.New("set server.tls_cert or server.autocert_hosts, not both")
+		}
+		cache := c.AutocertCache
+		if cache == "" {
+			cache = "autocert"
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(strings.Split(c.AutocertHosts, ",")...),
+			Cache:      autocert.DirCache(cache),
+		}
+		srv.TLSConfig = m.TLSConfig()
+
+	case c.TLSCert != "" || c.TLSKey != "":
+		if c.TLSCert == "" || c.TLSKey == "" {
+			return nil, errors.New("server.tls_cert and server.tls_key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	if srv.TLSConfig != nil {
+		srv.TLSConfig.MinVersion = tls.VersionTLS12
+	}
+
+	return srv, nil
+}
+
+// serve runs srv until SIGINT or SIGTERM, then stops accepting requests
+// and waits for in-flight requests and background command work to finish
+// within the shutdown timeout
+func serve(srv *http.Server, c config.Server) error {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "addr", srv.Addr, "tls", srv.TLSConfig != nil)
+		if srv.TLSConfig != nil {
+			errs <- srv.ListenAndServeTLS("", "")
+		} else {
+			errs <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-stop:
+		slog.Info("shutting down", "signal", sig.String())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout.Duration)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		return err
+	}
+
+	// replies still being delivered to response_urls
+	err = slack.Wait(ctx)
+	if err != nil {
+		return errors.New("shutdown timed out before background work finished")
+	}
+
+	slog.Info("shutdown complete")
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/jesselucas/slackcmd/config"
+	"github.com/jesselucas/slackcmd/slack"
+	"github.com/jesselucas/slackcmd/storage"
+)
+
+var (
+	settingsMu sync.RWMutex
+	// settings holds the resolved settings of each command by config section
+	settings = make(map[string]slack.Settings)
+	// disabled holds the required settings missing from a section. Its
+	// commands reply that they aren't configured.
+	disabled = make(map[string][]slack.Setting)
+)
+
+// applySettings resolves the settings of every command from c, disables
+// the commands missing required settings and logs what is missing
+func applySettings(c *config.Config) {
+	resolved := make(map[string]slack.Settings)
+	incomplete := make(map[string][]slack.Setting)
+	for _, ci := range slack.Commands() {
+		section := ci.ConfigSection()
+		s, missing := slack.ResolveSettings(ci.AllSettings(), c.Section(section))
+		resolved[section] = s
+
+		if required := slack.Required(missing); len(required) > 0 {
+			incomplete[section] = required
+			slog.Warn("command disabled, missing required settings", "command", ci.Name(), "missing", describeSettings(section, required))
+		}
+		for _, m := range missing {
+			if !m.Required {
+				slog.Info("setting is not set", "command", ci.Name(), "setting", describeSettings(section, []slack.Setting{m}), "usage", m.Usage)
+			}
+		}
+	}
+
+	for _, name := range c.Unset {
+		slog.Warn("config references an unset env var", "var", name)
+	}
+
+	settingsMu.Lock()
+	settings = resolved
+	disabled = incomplete
+	settingsMu.Unlock()
+}
+
+// describeSettings names each setting by its config key and env var,
+// Ex. "commands.trello.key (TRELLO_KEY)"
+func describeSettings(section string, defs []slack.Setting) string {
+	var names []string
+	for _, def := range defs {
+		name := "commands." + section + "." + def.Key
+		if def.Env != "" {
+			name += " (" + def.Env + ")"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// missingFor returns the required settings a command's section is missing
+func missingFor(section string) []slack.Setting {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return disabled[section]
+}
+
+// depsFor returns the Deps of the command with a config section
+func depsFor(section string) slack.Deps {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+
+	return slack.Deps{
+		Store:      storage.Namespace(store, section),
+		Settings:   settings[section],
+		HTTPClient: upstream,
+		Logger:     slog.Default().With("command", section),
+	}
+}
+
+// reloadOnHangup reloads the config file on SIGHUP. Command settings apply
+// to the next request. The server, slack, storage, log and rate_limit
+// sections are read at startup so changes to them need a restart.
+func reloadOnHangup(current *config.Config) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		c, err := config.Load(current.Path)
+		if err != nil {
+			slog.Error("config reload failed, keeping the current config", "err", err)
+			continue
+		}
+
+		if c.Server != current.Server || c.Slack != current.Slack || c.Storage != current.Storage || c.Log != current.Log || c.RateLimit != current.RateLimit {
+			slog.Warn("config: server, slack, storage, log and rate_limit changes apply after a restart")
+		}
+
+		applySettings(c)
+		slog.Info("config reloaded", "path", c.Path)
+		current = c
+	}
+}
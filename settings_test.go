@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jesselucas/slackcmd/config"
+	"github.com/jesselucas/slackcmd/slack"
+)
+
+// settingsCommand answers with the key setting it was made with
+type settingsCommand struct {
+	key string
+}
+
+func (c settingsCommand) Request(sc *slack.SlashCommand) (*slack.CommandPayload, error) {
+	return &slack.CommandPayload{Text: "key=" + c.key, SlashResponse: true}, nil
+}
+
+func init() {
+	slack.Register(slack.CommandInfo{
+		Names:    []string{"/testsettings"},
+		TokenEnv: "SLACK_KEY_TESTSETTINGS",
+		Settings: []slack.Setting{
+			{Key: "key", Usage: "API key", Required: true},
+			{Key: "org", Default: "forestgiant", Usage: "Organization"},
+		},
+		New: func(deps slack.Deps) slack.Command { return settingsCommand{deps.Settings.Get("key")} },
+	})
+}
+
+// resetSettings puts the settings TestMain applied back when the test is
+// done
+func resetSettings(t *testing.T) {
+	settingsMu.RLock()
+	previous, previousDisabled := settings, disabled
+	settingsMu.RUnlock()
+
+	t.Cleanup(func() {
+		settingsMu.Lock()
+		settings, disabled = previous, previousDisabled
+		settingsMu.Unlock()
+	})
+}
+
+func TestApplySettings(t *testing.T) {
+	resetSettings(t)
+
+	tests := []struct {
+		key      string
+		org      string
+		expected string
+	}{
+		{"", "", "Sorry, /testsettings is not configured yet."},
+		{"k1", "", "key=k1"},
+		{"k2", "fg", "key=k2"}, // a reload applies to the next request
+		{"", "fg", "Sorry, /testsettings is not configured yet."},
+	}
+
+	for i, test := range tests {
+		section := config.CommandSettings{"verification_token": "t"}
+		if test.key != "" {
+			section["key"] = test.key
+		}
+		if test.org != "" {
+			section["org"] = test.org
+		}
+		applySettings(&config.Config{Commands: map[string]config.CommandSettings{"testsettings": section}})
+
+		missing := len(missingFor("testsettings")) > 0
+		if missing != (test.key == "") {
+			t.Errorf("Test errored. Case %v disabled should be %v but is %v", i, test.key == "", missing)
+		}
+
+		deps := depsFor("testsettings")
+		expectedOrg := test.org
+		if expectedOrg == "" {
+			expectedOrg = "forestgiant"
+		}
+		if key, org := deps.Settings.Get("key"), deps.Settings.Get("org"); key != test.key || org != expectedOrg {
+			t.Errorf("Test errored. Case %v deps settings should be %q %q but are %q %q", i, test.key, expectedOrg, key, org)
+		}
+
+		w := runCommand(t, url.Values{
+			"command": {"/testsettings"},
+			"token":   {"t"},
+		})
+		reply := w.Body.String()
+		if test.key == "" {
+			var cp slack.CommandPayload
+			json.NewDecoder(w.Body).Decode(&cp)
+			if cp.ResponseType != slack.ResponseEphemeral {
+				t.Errorf("Test errored. Case %v reply should be ephemeral but is %q", i, cp.ResponseType)
+			}
+			reply = cp.Text
+		}
+		if !strings.HasPrefix(reply, test.expected) {
+			t.Errorf("Test errored. Case %v reply should be %q but is %q", i, test.expected, reply)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package slack
+
+import (
+	"context"
+	"sync"
+)
+
+// tracker counts work running in the background. Unlike a WaitGroup it can
+// be waited on with a deadline without leaving a waiter behind.
+type tracker struct {
+	mu      sync.Mutex
+	running int
+	idle    chan struct{} // closed while nothing is running
+}
+
+func newTracker() *tracker {
+	idle := make(chan struct{})
+	close(idle)
+	return &tracker{idle: idle}
+}
+
+func (t *tracker) add() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.running == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.running++
+}
+
+func (t *tracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.running--
+	if t.running == 0 {
+		close(t.idle)
+	}
+}
+
+// wait blocks until nothing is running or ctx is done
+func (t *tracker) wait(ctx context.Context) error {
+	t.mu.Lock()
+	idle := t.idle
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// goTracked runs fn in the background tracked by t. A panic in fn is
+// logged instead of crashing the server.
+func (t *tracker) goTracked(fn func()) {
+	t.add()
+	go func() {
+		defer t.done()
+		defer func() {
+			if v := recover(); v != nil {
+				logPanic("slack: background panic", v)
+			}
+		}()
+		fn()
+	}()
+}
+
+// background counts the work running after Slack was acknowledged so the
+// server can finish it before shutting down
+var background = newTracker()
+
+// Go runs fn in the background and tracks it until it returns. A panic in
+// fn is logged instead of crashing the server.
+func Go(fn func()) {
+	background.goTracked(fn)
+}
+
+// Wait blocks until the work started with Go finishes or ctx is done
+func Wait(ctx context.Context) error {
+	return background.wait(ctx)
+}
@@ -0,0 +1,47 @@
+package slack
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWait(t *testing.T) {
+	tr := newTracker()
+	release := make(chan struct{})
+	done := make(chan struct{})
+	tr.goTracked(func() {
+		<-release
+		close(done)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tr.wait(ctx); err == nil {
+		t.Error("Test errored. Wait should time out while work is running")
+	}
+
+	// work started after a Wait timed out is tracked too
+	later := make(chan struct{})
+	tr.goTracked(func() {
+		<-release
+		close(later)
+	})
+
+	close(release)
+	if err := tr.wait(context.Background()); err != nil {
+		t.Error("Test errored. Wait returned", err)
+	}
+	for _, ch := range []chan struct{}{done, later} {
+		select {
+		case <-ch:
+		default:
+			t.Error("Test errored. Wait returned before the work finished")
+		}
+	}
+
+	// nothing running
+	if err := newTracker().wait(context.Background()); err != nil {
+		t.Error("Test errored. Wait with nothing running returned", err)
+	}
+}
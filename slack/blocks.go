@@ -0,0 +1,554 @@
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Block Kit layout blocks - https://api.slack.com/reference/block-kit/blocks
+
+// Limits Slack enforces on Block Kit payloads
+const (
+	MaxMessageBlocks  = 50
+	MaxModalBlocks    = 100
+	MaxSectionText    = 3000
+	MaxHeaderText     = 150
+	MaxSectionFields  = 10
+	MaxFieldText      = 2000
+	MaxContextItems   = 10
+	MaxActionElements = 25
+	MaxButtonText     = 75
+	MaxBlockId        = 255
+	MaxActionId       = 255
+	MaxOptions        = 100
+)
+
+// Text object types
+const (
+	PlainText = "plain_text"
+	Markdown  = "mrkdwn"
+)
+
+// Block is a Block Kit layout block
+type Block interface {
+	BlockType() string
+	validate() error
+}
+
+// Element is an interactive element or image that can be placed in blocks
+type Element interface {
+	ElementType() string
+	validate() error
+}
+
+// TextObject is a plain_text or mrkdwn text object
+type TextObject struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Emoji    bool   `json:"emoji,omitempty"`
+	Verbatim bool   `json:"verbatim,omitempty"`
+}
+
+// NewPlainText returns a plain_text object
+func NewPlainText(text string) *TextObject {
+	return &TextObject{Type: PlainText, Text: text, Emoji: true}
+}
+
+// NewMarkdown returns a mrkdwn text object
+func NewMarkdown(text string) *TextObject {
+	return &TextObject{Type: Markdown, Text: text}
+}
+
+// ElementType lets a TextObject be used as a context element
+func (t *TextObject) ElementType() string {
+	return t.Type
+}
+
+func (t *TextObject) validate() error {
+	if t.Type != PlainText && t.Type != Markdown {
+		return fmt.Errorf("text type %q must be %v or %v", t.Type, PlainText, Markdown)
+	}
+	if t.Text == "" {
+		return errors.New("text must not be empty")
+	}
+	return nil
+}
+
+// Option is an item of a select or overflow menu
+type Option struct {
+	Text        *TextObject `json:"text"`
+	Value       string      `json:"value"`
+	Description *TextObject `json:"description,omitempty"`
+}
+
+// NewOption returns an option with plain text
+func NewOption(text string, value string) *Option {
+	return &Option{Text: NewPlainText(text), Value: value}
+}
+
+// SectionBlock displays text with optional fields and an accessory element
+type SectionBlock struct {
+	BlockId   string        `json:"block_id,omitempty"`
+	Text      *TextObject   `json:"text,omitempty"`
+	Fields    []*TextObject `json:"fields,omitempty"`
+	Accessory Element       `json:"accessory,omitempty"`
+}
+
+func (b *SectionBlock) BlockType() string { return "section" }
+
+func (b *SectionBlock) validate() error {
+	if b.Text == nil && len(b.Fields) == 0 {
+		return errors.New("section needs text or fields")
+	}
+	if b.Text != nil {
+		if err := checkText(b.Text, MaxSectionText); err != nil {
+			return err
+		}
+	}
+	if len(b.Fields) > MaxSectionFields {
+		return fmt.Errorf("section has %v fields, the limit is %v", len(b.Fields), MaxSectionFields)
+	}
+	for _, f := range b.Fields {
+		if err := checkText(f, MaxFieldText); err != nil {
+			return err
+		}
+	}
+	if b.Accessory != nil {
+		return b.Accessory.validate()
+	}
+	return nil
+}
+
+// DividerBlock is a horizontal rule
+type DividerBlock struct {
+	BlockId string `json:"block_id,omitempty"`
+}
+
+func (b *DividerBlock) BlockType() string { return "divider" }
+
+func (b *DividerBlock) validate() error { return nil }
+
+// HeaderBlock displays plain text in a larger font
+type HeaderBlock struct {
+	BlockId string      `json:"block_id,omitempty"`
+	Text    *TextObject `json:"text"`
+}
+
+func (b *HeaderBlock) BlockType() string { return "header" }
+
+func (b *HeaderBlock) validate() error {
+	if b.Text == nil || b.Text.Type != PlainText {
+		return errors.New("header text must be plain_text")
+	}
+	return checkText(b.Text, MaxHeaderText)
+}
+
+// ContextBlock displays small text and images
+type ContextBlock struct {
+	BlockId  string    `json:"block_id,omitempty"`
+	Elements []Element `json:"elements"`
+}
+
+func (b *ContextBlock) BlockType() string { return "context" }
+
+func (b *ContextBlock) validate() error {
+	if len(b.Elements) == 0 || len(b.Elements) > MaxContextItems {
+		return fmt.Errorf("context needs 1 to %v elements but has %v", MaxContextItems, len(b.Elements))
+	}
+	for _, e := range b.Elements {
+		switch e.(type) {
+		case *TextObject, *ImageElement:
+		default:
+			return fmt.Errorf("context can't contain a %v element", e.ElementType())
+		}
+		if err := e.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ActionsBlock holds interactive elements
+type ActionsBlock struct {
+	BlockId  string    `json:"block_id,omitempty"`
+	Elements []Element `json:"elements"`
+}
+
+func (b *ActionsBlock) BlockType() string { return "actions" }
+
+func (b *ActionsBlock) validate() error {
+	if len(b.Elements) == 0 || len(b.Elements) > MaxActionElements {
+		return fmt.Errorf("actions needs 1 to %v elements but has %v", MaxActionElements, len(b.Elements))
+	}
+	for _, e := range b.Elements {
+		if err := e.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImageBlock displays an image
+type ImageBlock struct {
+	BlockId  string      `json:"block_id,omitempty"`
+	ImageURL string      `json:"image_url"`
+	AltText  string      `json:"alt_text"`
+	Title    *TextObject `json:"title,omitempty"`
+}
+
+func (b *ImageBlock) BlockType() string { return "image" }
+
+func (b *ImageBlock) validate() error {
+	if b.ImageURL == "" || b.AltText == "" {
+		return errors.New("image needs image_url and alt_text")
+	}
+	return nil
+}
+
+// InputBlock collects input in modals
+type InputBlock struct {
+	BlockId  string      `json:"block_id,omitempty"`
+	Label    *TextObject `json:"label"`
+	Element  Element     `json:"element"`
+	Hint     *TextObject `json:"hint,omitempty"`
+	Optional bool        `json:"optional,omitempty"`
+}
+
+func (b *InputBlock) BlockType() string { return "input" }
+
+func (b *InputBlock) validate() error {
+	if b.Label == nil || b.Element == nil {
+		return errors.New("input needs a label and element")
+	}
+	if err := checkText(b.Label, 2000); err != nil {
+		return err
+	}
+	return b.Element.validate()
+}
+
+// ButtonElement is a button that sends a block_actions payload when clicked
+type ButtonElement struct {
+	Text     *TextObject `json:"text"`
+	ActionId string      `json:"action_id,omitempty"`
+	Value    string      `json:"value,omitempty"`
+	URL      string      `json:"url,omitempty"`
+	Style    string      `json:"style,omitempty"` // "primary" or "danger"
+}
+
+// NewButton returns a button with plain text
+func NewButton(actionID string, text string, value string) *ButtonElement {
+	return &ButtonElement{Text: NewPlainText(text), ActionId: actionID, Value: value}
+}
+
+func (e *ButtonElement) ElementType() string { return "button" }
+
+func (e *ButtonElement) validate() error {
+	if e.Text == nil || e.Text.Type != PlainText {
+		return errors.New("button text must be plain_text")
+	}
+	if err := checkText(e.Text, MaxButtonText); err != nil {
+		return err
+	}
+	return checkActionId(e.ActionId)
+}
+
+// StaticSelectElement is a menu of options
+type StaticSelectElement struct {
+	ActionId      string      `json:"action_id,omitempty"`
+	Placeholder   *TextObject `json:"placeholder,omitempty"`
+	Options       []*Option   `json:"options"`
+	InitialOption *Option     `json:"initial_option,omitempty"`
+}
+
+func (e *StaticSelectElement) ElementType() string { return "static_select" }
+
+func (e *StaticSelectElement) validate() error {
+	if len(e.Options) == 0 || len(e.Options) > MaxOptions {
+		return fmt.Errorf("select needs 1 to %v options but has %v", MaxOptions, len(e.Options))
+	}
+	return checkActionId(e.ActionId)
+}
+
+// OverflowElement is a compact menu of up to 5 options
+type OverflowElement struct {
+	ActionId string    `json:"action_id,omitempty"`
+	Options  []*Option `json:"options"`
+}
+
+func (e *OverflowElement) ElementType() string { return "overflow" }
+
+func (e *OverflowElement) validate() error {
+	if len(e.Options) < 2 || len(e.Options) > 5 {
+		return fmt.Errorf("overflow needs 2 to 5 options but has %v", len(e.Options))
+	}
+	return checkActionId(e.ActionId)
+}
+
+// DatePickerElement lets a user pick a date written as DateLayout
+type DatePickerElement struct {
+	ActionId    string      `json:"action_id,omitempty"`
+	Placeholder *TextObject `json:"placeholder,omitempty"`
+	InitialDate string      `json:"initial_date,omitempty"`
+}
+
+func (e *DatePickerElement) ElementType() string { return "datepicker" }
+
+func (e *DatePickerElement) validate() error {
+	return checkActionId(e.ActionId)
+}
+
+// PlainTextInputElement is a text field used in input blocks
+type PlainTextInputElement struct {
+	ActionId     string      `json:"action_id,omitempty"`
+	Placeholder  *TextObject `json:"placeholder,omitempty"`
+	InitialValue string      `json:"initial_value,omitempty"`
+	Multiline    bool        `json:"multiline,omitempty"`
+	MaxLength    int         `json:"max_length,omitempty"`
+}
+
+func (e *PlainTextInputElement) ElementType() string { return "plain_text_input" }
+
+func (e *PlainTextInputElement) validate() error {
+	return checkActionId(e.ActionId)
+}
+
+// ImageElement is an image used in context blocks and section accessories
+type ImageElement struct {
+	ImageURL string `json:"image_url"`
+	AltText  string `json:"alt_text"`
+}
+
+func (e *ImageElement) ElementType() string { return "image" }
+
+func (e *ImageElement) validate() error {
+	if e.ImageURL == "" || e.AltText == "" {
+		return errors.New("image needs image_url and alt_text")
+	}
+	return nil
+}
+
+// MarshalJSON adds the "type" field to every block and element
+func (b *SectionBlock) MarshalJSON() ([]byte, error) {
+	type alias SectionBlock
+	return marshalTyped(b.BlockType(), (*alias)(b))
+}
+
+func (b *DividerBlock) MarshalJSON() ([]byte, error) {
+	type alias DividerBlock
+	return marshalTyped(b.BlockType(), (*alias)(b))
+}
+
+func (b *HeaderBlock) MarshalJSON() ([]byte, error) {
+	type alias HeaderBlock
+	return marshalTyped(b.BlockType(), (*alias)(b))
+}
+
+func (b *ContextBlock) MarshalJSON() ([]byte, error) {
+	type alias ContextBlock
+	return marshalTyped(b.BlockType(), (*alias)(b))
+}
+
+func (b *ActionsBlock) MarshalJSON() ([]byte, error) {
+	type alias ActionsBlock
+	return marshalTyped(b.BlockType(), (*alias)(b))
+}
+
+func (b *ImageBlock) MarshalJSON() ([]byte, error) {
+	type alias ImageBlock
+	return marshalTyped(b.BlockType(), (*alias)(b))
+}
+
+func (b *InputBlock) MarshalJSON() ([]byte, error) {
+	type alias InputBlock
+	return marshalTyped(b.BlockType(), (*alias)(b))
+}
+
+func (e *ButtonElement) MarshalJSON() ([]byte, error) {
+	type alias ButtonElement
+	return marshalTyped(e.ElementType(), (*alias)(e))
+}
+
+func (e *StaticSelectElement) MarshalJSON() ([]byte, error) {
+	type alias StaticSelectElement
+	return marshalTyped(e.ElementType(), (*alias)(e))
+}
+
+func (e *OverflowElement) MarshalJSON() ([]byte, error) {
+	type alias OverflowElement
+	return marshalTyped(e.ElementType(), (*alias)(e))
+}
+
+func (e *DatePickerElement) MarshalJSON() ([]byte, error) {
+	type alias DatePickerElement
+	return marshalTyped(e.ElementType(), (*alias)(e))
+}
+
+func (e *PlainTextInputElement) MarshalJSON() ([]byte, error) {
+	type alias PlainTextInputElement
+	return marshalTyped(e.ElementType(), (*alias)(e))
+}
+
+func (e *ImageElement) MarshalJSON() ([]byte, error) {
+	type alias ImageElement
+	return marshalTyped(e.ElementType(), (*alias)(e))
+}
+
+// marshalTyped marshals v with a leading "type" field
+func marshalTyped(typ string, v interface{}) ([]byte, error) {
+	fields, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := fmt.Sprintf(`{"type":%q`, typ)
+	if len(fields) > 2 {
+		typed += ","
+	}
+
+	return append([]byte(typed), fields[1:]...), nil
+}
+
+func checkText(t *TextObject, limit int) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	if n := utf8.RuneCountInString(t.Text); n > limit {
+		return fmt.Errorf("text is %v characters, the limit is %v", n, limit)
+	}
+	return nil
+}
+
+func checkActionId(id string) error {
+	if len(id) > MaxActionId {
+		return fmt.Errorf("action_id is %v characters, the limit is %v", len(id), MaxActionId)
+	}
+	return nil
+}
+
+// ValidateBlocks checks blocks against Slack's limits for a message.
+// Use MaxModalBlocks as max for modals.
+func ValidateBlocks(blocks []Block, max int) error {
+	if len(blocks) > max {
+		return fmt.Errorf("slack: %v blocks, the limit is %v", len(blocks), max)
+	}
+
+	ids := make(map[string]bool)
+	for i, b := range blocks {
+		if err := b.validate(); err != nil {
+			return fmt.Errorf("slack: %v block %v: %v", b.BlockType(), i, err)
+		}
+
+		id := blockId(b)
+		if len(id) > MaxBlockId {
+			return fmt.Errorf("slack: %v block %v: block_id is longer than %v characters", b.BlockType(), i, MaxBlockId)
+		}
+		if id != "" && ids[id] {
+			return fmt.Errorf("slack: %v block %v: duplicate block_id %q", b.BlockType(), i, id)
+		}
+		ids[id] = true
+	}
+
+	return nil
+}
+
+func blockId(b Block) string {
+	switch b := b.(type) {
+	case *SectionBlock:
+		return b.BlockId
+	case *DividerBlock:
+		return b.BlockId
+	case *HeaderBlock:
+		return b.BlockId
+	case *ContextBlock:
+		return b.BlockId
+	case *ActionsBlock:
+		return b.BlockId
+	case *ImageBlock:
+		return b.BlockId
+	case *InputBlock:
+		return b.BlockId
+	}
+	return ""
+}
+
+// BlockBuilder builds a list of blocks with chained calls
+// Ex. NewBlocks().Header("Schedule").Section("*9am* Standup").Divider().Blocks()
+type BlockBuilder struct {
+	blocks []Block
+}
+
+// NewBlocks returns an empty BlockBuilder
+func NewBlocks() *BlockBuilder {
+	return &BlockBuilder{}
+}
+
+// Add appends any block
+func (bb *BlockBuilder) Add(b Block) *BlockBuilder {
+	bb.blocks = append(bb.blocks, b)
+	return bb
+}
+
+// Header appends a header block with plain text
+func (bb *BlockBuilder) Header(text string) *BlockBuilder {
+	return bb.Add(&HeaderBlock{Text: NewPlainText(text)})
+}
+
+// Section appends a section block with mrkdwn text
+func (bb *BlockBuilder) Section(text string) *BlockBuilder {
+	return bb.Add(&SectionBlock{Text: NewMarkdown(text)})
+}
+
+// SectionWithAccessory appends a mrkdwn section with an element beside it
+func (bb *BlockBuilder) SectionWithAccessory(text string, accessory Element) *BlockBuilder {
+	return bb.Add(&SectionBlock{Text: NewMarkdown(text), Accessory: accessory})
+}
+
+// Fields appends a section block of mrkdwn fields shown in two columns
+func (bb *BlockBuilder) Fields(fields ...string) *BlockBuilder {
+	s := &SectionBlock{}
+	for _, f := range fields {
+		s.Fields = append(s.Fields, NewMarkdown(f))
+	}
+	return bb.Add(s)
+}
+
+// Divider appends a divider block
+func (bb *BlockBuilder) Divider() *BlockBuilder {
+	return bb.Add(&DividerBlock{})
+}
+
+// Context appends a context block of mrkdwn text
+func (bb *BlockBuilder) Context(texts ...string) *BlockBuilder {
+	c := &ContextBlock{}
+	for _, t := range texts {
+		c.Elements = append(c.Elements, NewMarkdown(t))
+	}
+	return bb.Add(c)
+}
+
+// Actions appends an actions block
+func (bb *BlockBuilder) Actions(blockID string, elements ...Element) *BlockBuilder {
+	return bb.Add(&ActionsBlock{BlockId: blockID, Elements: elements})
+}
+
+// Image appends an image block
+func (bb *BlockBuilder) Image(url string, altText string) *BlockBuilder {
+	return bb.Add(&ImageBlock{ImageURL: url, AltText: altText})
+}
+
+// Input appends an input block
+func (bb *BlockBuilder) Input(blockID string, label string, element Element, optional bool) *BlockBuilder {
+	return bb.Add(&InputBlock{BlockId: blockID, Label: NewPlainText(label), Element: element, Optional: optional})
+}
+
+// Blocks returns the blocks built so far
+func (bb *BlockBuilder) Blocks() []Block {
+	return bb.blocks
+}
+
+// Validate checks the blocks against the limits for a message
+func (bb *BlockBuilder) Validate() error {
+	return ValidateBlocks(bb.blocks, MaxMessageBlocks)
+}
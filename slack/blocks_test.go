@@ -0,0 +1,77 @@
+package slack
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBlockBuilderJSON(t *testing.T) {
+	blocks := NewBlocks().
+		Header("Conference Room Schedule").
+		SectionWithAccessory("*09:00AM to 10:00AM* Standup", NewButton("book", "Book", "0900")).
+		Fields("*Room*", "Boardroom").
+		Divider().
+		Context("3 events").
+		Actions("pick",
+			&StaticSelectElement{ActionId: "room", Options: []*Option{NewOption("Boardroom", "boardroom")}},
+			&OverflowElement{ActionId: "more", Options: []*Option{NewOption("Edit", "edit"), NewOption("Delete", "delete")}},
+			&DatePickerElement{ActionId: "date", InitialDate: "2026-10-20"},
+		).
+		Blocks()
+
+	b, err := json.Marshal(blocks)
+	if err != nil {
+		t.Fatal("Test errored. Marshal returned", err)
+	}
+
+	expected := `[` +
+		`{"type":"header","text":{"type":"plain_text","text":"Conference Room Schedule","emoji":true}},` +
+		`{"type":"section","text":{"type":"mrkdwn","text":"*09:00AM to 10:00AM* Standup"},"accessory":{"type":"button","text":{"type":"plain_text","text":"Book","emoji":true},"action_id":"book","value":"0900"}},` +
+		`{"type":"section","fields":[{"type":"mrkdwn","text":"*Room*"},{"type":"mrkdwn","text":"Boardroom"}]},` +
+		`{"type":"divider"},` +
+		`{"type":"context","elements":[{"type":"mrkdwn","text":"3 events"}]},` +
+		`{"type":"actions","block_id":"pick","elements":[` +
+		`{"type":"static_select","action_id":"room","options":[{"text":{"type":"plain_text","text":"Boardroom","emoji":true},"value":"boardroom"}]},` +
+		`{"type":"overflow","action_id":"more","options":[{"text":{"type":"plain_text","text":"Edit","emoji":true},"value":"edit"},{"text":{"type":"plain_text","text":"Delete","emoji":true},"value":"delete"}]},` +
+		`{"type":"datepicker","action_id":"date","initial_date":"2026-10-20"}]}` +
+		`]`
+
+	if string(b) != expected {
+		t.Errorf("Test errored. JSON should be\n%v\nbut is\n%v", expected, string(b))
+	}
+
+	if err := ValidateBlocks(blocks, MaxMessageBlocks); err != nil {
+		t.Error("Test errored. Blocks should be valid but returned", err)
+	}
+}
+
+func TestValidateBlocks(t *testing.T) {
+	tooMany := NewBlocks()
+	for i := 0; i <= MaxMessageBlocks; i++ {
+		tooMany.Divider()
+	}
+
+	tests := []struct {
+		blocks []Block
+		err    string
+	}{
+		{tooMany.Blocks(), "51 blocks, the limit is 50"},
+		{NewBlocks().Section(strings.Repeat("a", MaxSectionText+1)).Blocks(), "text is 3001 characters"},
+		{NewBlocks().Header(strings.Repeat("a", MaxHeaderText+1)).Blocks(), "text is 151 characters"},
+		{NewBlocks().Section("").Blocks(), "text must not be empty"},
+		{NewBlocks().Add(&HeaderBlock{Text: NewMarkdown("*bold*")}).Blocks(), "header text must be plain_text"},
+		{NewBlocks().Actions("a").Blocks(), "actions needs 1 to 25 elements"},
+		{NewBlocks().Actions("a", NewButton("b", strings.Repeat("a", MaxButtonText+1), "")).Blocks(), "text is 76 characters"},
+		{NewBlocks().Add(&ContextBlock{Elements: []Element{NewButton("b", "Book", "")}}).Blocks(), "context can't contain a button"},
+		{NewBlocks().Add(&SectionBlock{BlockId: "a", Text: NewMarkdown("a")}).Add(&DividerBlock{BlockId: "a"}).Blocks(), `duplicate block_id "a"`},
+		{NewBlocks().Actions("a", &OverflowElement{Options: []*Option{NewOption("Edit", "edit")}}).Blocks(), "overflow needs 2 to 5 options"},
+	}
+
+	for _, test := range tests {
+		err := ValidateBlocks(test.blocks, MaxMessageBlocks)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Test errored. Error should contain %q but is %v", test.err, err)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the base URL of the Slack Web API
+const DefaultAPIURL = "https://slack.com/api/"
+
+// Client calls Slack Web API methods with a bot token
+// https://api.slack.com/web
+type Client struct {
+	Token      string
+	BaseURL    string       // defaults to DefaultAPIURL
+	HTTPClient *http.Client // defaults to http.DefaultClient
+	MaxRetries int          // times a rate limited call is retried
+}
+
+// NewClient returns a Client for the bot token
+func NewClient(token string) *Client {
+	return &Client{
+		Token:      token,
+		BaseURL:    DefaultAPIURL,
+		HTTPClient: http.DefaultClient,
+		MaxRetries: 3,
+	}
+}
+
+// APIError is returned when a method answers with ok:false
+type APIError struct {
+	Method   string
+	Code     string   // Ex. channel_not_found
+	Messages []string // details from response_metadata
+}
+
+func (e *APIError) Error() string {
+	if len(e.Messages) > 0 {
+		return fmt.Sprintf("slack: %v: %v (%v)", e.Method, e.Code, strings.Join(e.Messages, "; "))
+	}
+	return fmt.Sprintf("slack: %v: %v", e.Method, e.Code)
+}
+
+// RateLimitError is returned when a call is still rate limited after
+// MaxRetries
+type RateLimitError struct {
+	Method     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("slack: %v: rate limited, retry after %v", e.Method, e.RetryAfter)
+}
+
+// response is the envelope of every Web API response
+type response struct {
+	Ok               bool   `json:"ok"`
+	Error            string `json:"error"`
+	ResponseMetadata struct {
+		Messages []string `json:"messages"`
+	} `json:"response_metadata"`
+}
+
+// callJSON posts params as JSON to a method and decodes the response into v
+func (c *Client) callJSON(ctx context.Context, method string, params interface{}, v interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, method, "application/json; charset=utf-8", body, v)
+}
+
+// callForm posts params form encoded to a method. Read methods like
+// users.info don't accept JSON.
+func (c *Client) callForm(ctx context.Context, method string, params url.Values, v interface{}) error {
+	return c.do(ctx, method, "application/x-www-form-urlencoded", []byte(params.Encode()), v)
+}
+
+func (c *Client) do(ctx context.Context, method string, contentType string, body []byte, v interface{}) error {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultAPIURL
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", base+method, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", contentType)
+		if c.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		resBody, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		// wait out rate limits - https://api.slack.com/docs/rate-limits
+		if res.StatusCode == http.StatusTooManyRequests {
+			wait := retryAfter(res.Header)
+			if attempt >= c.MaxRetries {
+				return &RateLimitError{method, wait}
+			}
+
+			select {
+			case <-time.After(wait):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("slack: %v returned %v", method, res.Status)
+		}
+
+		var r response
+		err = json.Unmarshal(resBody, &r)
+		if err != nil {
+			return err
+		}
+		if !r.Ok {
+			return &APIError{method, r.Error, r.ResponseMetadata.Messages}
+		}
+
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(resBody, v)
+	}
+}
+
+// retryAfter reads the seconds to wait from the Retry-After header
+func retryAfter(h http.Header) time.Duration {
+	seconds, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// message adds the fields chat methods need to a payload
+type message struct {
+	*CommandPayload
+	Channel string `json:"channel"`
+	User    string `json:"user,omitempty"`
+	Ts      string `json:"ts,omitempty"`
+}
+
+// PostMessage sends the payload to a channel and returns the channel ID and
+// timestamp of the message
+func (c *Client) PostMessage(ctx context.Context, channel string, cp *CommandPayload) (string, string, error) {
+	var r struct {
+		Channel string `json:"channel"`
+		Ts      string `json:"ts"`
+	}
+	err := c.callJSON(ctx, "chat.postMessage", message{CommandPayload: cp, Channel: channel}, &r)
+	return r.Channel, r.Ts, err
+}
+
+// PostEphemeral sends the payload to one user in a channel and returns the
+// timestamp of the message
+func (c *Client) PostEphemeral(ctx context.Context, channel string, user string, cp *CommandPayload) (string, error) {
+	var r struct {
+		MessageTs string `json:"message_ts"`
+	}
+	err := c.callJSON(ctx, "chat.postEphemeral", message{CommandPayload: cp, Channel: channel, User: user}, &r)
+	return r.MessageTs, err
+}
+
+// UpdateMessage replaces the message at ts with the payload
+func (c *Client) UpdateMessage(ctx context.Context, channel string, ts string, cp *CommandPayload) error {
+	return c.callJSON(ctx, "chat.update", message{CommandPayload: cp, Channel: channel, Ts: ts}, nil)
+}
+
+// DeleteMessage deletes the message at ts
+func (c *Client) DeleteMessage(ctx context.Context, channel string, ts string) error {
+	return c.callJSON(ctx, "chat.delete", map[string]string{"channel": channel, "ts": ts}, nil)
+}
+
+// Unfurl previews the links in the message at ts. unfurls is keyed by URL.
+func (c *Client) Unfurl(ctx context.Context, channel string, ts string, unfurls map[string]Attachment) error {
+	return c.callJSON(ctx, "chat.unfurl", map[string]interface{}{"channel": channel, "ts": ts, "unfurls": unfurls}, nil)
+}
+
+type viewResponse struct {
+	View *View `json:"view"`
+}
+
+// OpenView opens a modal for the user that triggered trigger_id
+func (c *Client) OpenView(ctx context.Context, triggerID string, v *View) (*View, error) {
+	var r viewResponse
+	err := c.callJSON(ctx, "views.open", map[string]interface{}{"trigger_id": triggerID, "view": v}, &r)
+	return r.View, err
+}
+
+// UpdateView replaces an open view. hash may be empty, otherwise the update
+// fails if the view changed since hash was returned.
+func (c *Client) UpdateView(ctx context.Context, viewID string, hash string, v *View) (*View, error) {
+	params := map[string]interface{}{"view_id": viewID, "view": v}
+	if hash != "" {
+		params["hash"] = hash
+	}
+
+	var r viewResponse
+	err := c.callJSON(ctx, "views.update", params, &r)
+	return r.View, err
+}
+
+// PushView pushes a view on top of the open modal
+func (c *Client) PushView(ctx context.Context, triggerID string, v *View) (*View, error) {
+	var r viewResponse
+	err := c.callJSON(ctx, "views.push", map[string]interface{}{"trigger_id": triggerID, "view": v}, &r)
+	return r.View, err
+}
+
+// UserInfo looks up a user by ID
+func (c *Client) UserInfo(ctx context.Context, userID string) (*User, error) {
+	var r struct {
+		User *User `json:"user"`
+	}
+	err := c.callForm(ctx, "users.info", url.Values{"user": {userID}}, &r)
+	return r.User, err
+}
+
+// ConversationInfo looks up a channel, group or DM by ID
+func (c *Client) ConversationInfo(ctx context.Context, channelID string) (*Channel, error) {
+	var r struct {
+		Channel *Channel `json:"channel"`
+	}
+	err := c.callForm(ctx, "conversations.info", url.Values{"channel": {channelID}}, &r)
+	return r.Channel, err
+}
+
+// FileUpload describes a file to share with UploadFile
+type FileUpload struct {
+	Filename       string
+	Title          string
+	Content        []byte
+	Channel        string // optional, the file is private when empty
+	InitialComment string
+	ThreadTs       string
+}
+
+// UploadFile uploads and shares a file the way files.uploadV2 does in
+// Slack's SDKs: get an upload URL, send the content to it, then complete
+// the upload. It returns the file ID.
+func (c *Client) UploadFile(ctx context.Context, f FileUpload) (string, error) {
+	var upload struct {
+		UploadURL string `json:"upload_url"`
+		FileId    string `json:"file_id"`
+	}
+	params := url.Values{
+		"filename": {f.Filename},
+		"length":   {strconv.Itoa(len(f.Content))},
+	}
+	err := c.callForm(ctx, "files.getUploadURLExternal", params, &upload)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", upload.UploadURL, bytes.NewReader(f.Content))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("slack: file upload returned %v", res.Status)
+	}
+
+	title := f.Title
+	if title == "" {
+		title = f.Filename
+	}
+	complete := map[string]interface{}{
+		"files": []map[string]string{{"id": upload.FileId, "title": title}},
+	}
+	if f.Channel != "" {
+		complete["channel_id"] = f.Channel
+	}
+	if f.InitialComment != "" {
+		complete["initial_comment"] = f.InitialComment
+	}
+	if f.ThreadTs != "" {
+		complete["thread_ts"] = f.ThreadTs
+	}
+
+	err = c.callJSON(ctx, "files.completeUploadExternal", complete, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return upload.FileId, nil
+}
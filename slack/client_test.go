@@ -0,0 +1,131 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeAPI answers Web API methods with canned responses and records the
+// requests it receives
+type fakeAPI struct {
+	responses map[string][]string // method → bodies returned in order
+	requests  map[string][]string // method → bodies received
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/")
+	body, _ := ioutil.ReadAll(r.Body)
+	f.requests[method] = append(f.requests[method], string(body))
+
+	// oauth.v2.access authenticates with the client secret instead
+	if method != "oauth.v2.access" && r.Header.Get("Authorization") != "Bearer xoxb-test" {
+		w.Write([]byte(`{"ok":false,"error":"not_authed"}`))
+		return
+	}
+
+	bodies := f.responses[method]
+	if len(bodies) == 0 {
+		w.Write([]byte(`{"ok":true}`))
+		return
+	}
+	f.responses[method] = bodies[1:]
+
+	if bodies[0] == "429" {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	w.Write([]byte(bodies[0]))
+}
+
+func newFakeAPI(responses map[string][]string) (*fakeAPI, *httptest.Server, *Client) {
+	f := &fakeAPI{responses: responses, requests: make(map[string][]string)}
+	ts := httptest.NewServer(f)
+	c := NewClient("xoxb-test")
+	c.BaseURL = ts.URL
+	return f, ts, c
+}
+
+func TestClientPostMessage(t *testing.T) {
+	f, ts, c := newFakeAPI(map[string][]string{
+		"chat.postMessage": {"429", `{"ok":true,"channel":"C1","ts":"1503435956.000247"}`},
+	})
+	defer ts.Close()
+
+	channel, timestamp, err := c.PostMessage(context.Background(), "#general", &CommandPayload{Text: "hi", SendPayload: true})
+	if err != nil {
+		t.Fatal("Test errored. PostMessage returned", err)
+	}
+	if channel != "C1" || timestamp != "1503435956.000247" {
+		t.Errorf("Test errored. Message should be C1 1503435956.000247 but is %v %v", channel, timestamp)
+	}
+
+	if len(f.requests["chat.postMessage"]) != 2 {
+		t.Fatalf("Test errored. Rate limited call should be retried once but was sent %v times", len(f.requests["chat.postMessage"]))
+	}
+	var sent map[string]interface{}
+	json.Unmarshal([]byte(f.requests["chat.postMessage"][1]), &sent)
+	if sent["channel"] != "#general" || sent["text"] != "hi" {
+		t.Errorf("Test errored. Request should have channel and text but is %v", sent)
+	}
+	if _, ok := sent["SendPayload"]; ok {
+		t.Error("Test errored. SendPayload should not be sent to Slack")
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	_, ts, c := newFakeAPI(map[string][]string{
+		"users.info":       {`{"ok":false,"error":"user_not_found"}`},
+		"chat.postMessage": {"429", "429"},
+	})
+	defer ts.Close()
+
+	_, err := c.UserInfo(context.Background(), "U0")
+	if apiErr, ok := err.(*APIError); !ok || apiErr.Code != "user_not_found" {
+		t.Errorf("Test errored. Error should be user_not_found but is %v", err)
+	}
+
+	c.MaxRetries = 1
+	_, _, err = c.PostMessage(context.Background(), "C1", &CommandPayload{Text: "hi"})
+	if _, ok := err.(*RateLimitError); !ok {
+		t.Errorf("Test errored. Error should be a RateLimitError but is %v", err)
+	}
+
+	c.Token = "xoxb-wrong"
+	err = c.DeleteMessage(context.Background(), "C1", "1")
+	if err == nil || err.Error() != "slack: chat.delete: not_authed" {
+		t.Errorf("Test errored. Error should be not_authed but is %v", err)
+	}
+}
+
+func TestClientUploadFile(t *testing.T) {
+	var uploaded string
+	upload := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		uploaded = string(body)
+	}))
+	defer upload.Close()
+
+	f, ts, c := newFakeAPI(map[string][]string{
+		"files.getUploadURLExternal": {`{"ok":true,"upload_url":"` + upload.URL + `","file_id":"F1"}`},
+	})
+	defer ts.Close()
+
+	id, err := c.UploadFile(context.Background(), FileUpload{Filename: "qotd.txt", Content: []byte("questions"), Channel: "C1"})
+	if err != nil {
+		t.Fatal("Test errored. UploadFile returned", err)
+	}
+	if id != "F1" || uploaded != "questions" {
+		t.Errorf("Test errored. File F1 should be uploaded with questions but is %v with %q", id, uploaded)
+	}
+
+	expected := `{"channel_id":"C1","files":[{"id":"F1","title":"qotd.txt"}]}`
+	if got := f.requests["files.completeUploadExternal"]; len(got) != 1 || got[0] != expected {
+		t.Errorf("Test errored. Complete should be %v but is %v", expected, got)
+	}
+}
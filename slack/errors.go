@@ -0,0 +1,131 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// ErrorKind is what went wrong with a command and picks the reply the user
+// sees
+type ErrorKind int
+
+const (
+	Internal      ErrorKind = iota // a bug or unexpected failure
+	Unauthorized                   // the request or user isn't allowed
+	Misconfigured                  // the command is missing or has bad settings
+	BadInput                       // the user typed something the command can't use
+	Upstream                       // a service the command calls failed or timed out
+)
+
+var kindNames = map[ErrorKind]string{
+	Internal:      "internal",
+	Unauthorized:  "unauthorized",
+	Misconfigured: "misconfigured",
+	BadInput:      "bad_input",
+	Upstream:      "upstream",
+}
+
+func (k ErrorKind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+// replies by kind, %v is the command name
+var kindReplies = map[ErrorKind]string{
+	Internal:      "Sorry, %v was unable to complete your request.",
+	Unauthorized:  "Sorry, you aren't allowed to use %v.",
+	Misconfigured: "Sorry, %v is not configured yet. Ask an admin to finish setting it up.",
+	BadInput:      "Sorry, %v didn't understand that.",
+	Upstream:      "Sorry, %v couldn't reach a service it depends on. Try again in a few minutes.",
+}
+
+// Error is a command error the user is told about. Message is shown to the
+// user in place of the kind's reply. Err is the cause and is only logged.
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Err != nil && e.Message != "":
+		return fmt.Sprintf("slack: %v: %v: %v", e.Kind, e.Message, e.Err)
+	case e.Err != nil:
+		return fmt.Sprintf("slack: %v: %v", e.Kind, e.Err)
+	case e.Message != "":
+		return fmt.Sprintf("slack: %v: %v", e.Kind, e.Message)
+	}
+	return "slack: " + e.Kind.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errorf returns an Error of kind with a message for the user, Ex.
+// Errorf(BadInput, "There's no board named %q.", name)
+func Errorf(kind ErrorKind, format string, a ...interface{}) error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+// WrapError returns an Error of kind caused by err, or nil if err is nil
+func WrapError(kind ErrorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// KindOf returns the kind of err. Errors that aren't an *Error are sorted
+// by what they are: ErrNotConfigured is Misconfigured, and timeouts, failed
+// HTTP requests and Slack API errors are Upstream. Anything else is
+// Internal.
+func KindOf(err error) ErrorKind {
+	var e *Error
+	var urlErr *url.Error
+	var apiErr *APIError
+	var rateErr *RateLimitError
+
+	switch {
+	case errors.As(err, &e):
+		return e.Kind
+	case errors.Is(err, ErrNotConfigured):
+		return Misconfigured
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &urlErr),
+		errors.As(err, &apiErr),
+		errors.As(err, &rateErr):
+		return Upstream
+	}
+	return Internal
+}
+
+// ErrorPayload returns the ephemeral reply telling the user of command
+// about err. ref is the ID of the request in the logs and is shown so the
+// user can report it. The reply goes back to the user even when the
+// command would have sent its payload elsewhere.
+func ErrorPayload(command string, err error, ref string) *CommandPayload {
+	if command == "" {
+		command = "this"
+	}
+
+	text := fmt.Sprintf(kindReplies[KindOf(err)], command)
+	var e *Error
+	if errors.As(err, &e) && e.Message != "" {
+		text = e.Message
+	}
+	if ref != "" {
+		text += fmt.Sprintf(" (ref `%v`)", ref)
+	}
+
+	return &CommandPayload{
+		Text:          text,
+		ResponseType:  ResponseEphemeral,
+		SlashResponse: true,
+	}
+}
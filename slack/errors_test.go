@@ -0,0 +1,67 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected ErrorKind
+	}{
+		{errors.New("boom"), Internal},
+		{ErrNotConfigured, Misconfigured},
+		{fmt.Errorf("trello: %w", ErrNotConfigured), Misconfigured},
+		{Errorf(BadInput, "There's no board named %q.", "Dev"), BadInput},
+		{WrapError(Unauthorized, errors.New("invalid token")), Unauthorized},
+		{fmt.Errorf("wrapped: %w", WrapError(Upstream, errors.New("502"))), Upstream},
+		{context.DeadlineExceeded, Upstream},
+		{&url.Error{Op: "Get", URL: "https://api.trello.com", Err: errors.New("refused")}, Upstream},
+		{&APIError{Method: "chat.postMessage", Code: "channel_not_found"}, Upstream},
+	}
+
+	for _, test := range tests {
+		if kind := KindOf(test.err); kind != test.expected {
+			t.Errorf("Test errored. Kind of %v should be %v but is %v", test.err, test.expected, kind)
+		}
+	}
+}
+
+func TestErrorPayload(t *testing.T) {
+	tests := []struct {
+		command  string
+		err      error
+		ref      string
+		expected string
+	}{
+		{"/fg", errors.New("json: bad"), "abc", "Sorry, /fg was unable to complete your request. (ref `abc`)"},
+		{"/qotd", ErrNotConfigured, "", "Sorry, /qotd is not configured yet. Ask an admin to finish setting it up."},
+		{"/fg", WrapError(Upstream, errors.New("trello: 503")), "abc", "Sorry, /fg couldn't reach a service it depends on. Try again in a few minutes. (ref `abc`)"},
+		{"/fg", Errorf(BadInput, "There's no board named %q.", "Dev"), "abc", "There's no board named \"Dev\". (ref `abc`)"},
+		{"", WrapError(Unauthorized, errors.New("invalid token")), "", "Sorry, you aren't allowed to use this."},
+	}
+
+	for _, test := range tests {
+		cp := ErrorPayload(test.command, test.err, test.ref)
+		if cp.Text != test.expected {
+			t.Errorf("Test errored. Text should be %q but is %q", test.expected, cp.Text)
+		}
+		if cp.ResponseType != ResponseEphemeral || !cp.SlashResponse {
+			t.Errorf("Test errored. Reply should be an ephemeral slash response but is %v, %v", cp.ResponseType, cp.SlashResponse)
+		}
+	}
+
+	// the cause is logged but never shown to the user
+	err := WrapError(Upstream, errors.New("GET https://api.trello.com/1?token=secret"))
+	if cp := ErrorPayload("/fg", err, ""); strings.Contains(cp.Text, "secret") {
+		t.Errorf("Test errored. Text should not contain the cause but is %q", cp.Text)
+	}
+	if !strings.Contains(err.Error(), "upstream") {
+		t.Errorf("Test errored. Error should contain the kind but is %q", err.Error())
+	}
+}
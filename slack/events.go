@@ -0,0 +1,268 @@
+package slack
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jesselucas/slackcmd/logging"
+)
+
+// Events commands can handle - https://api.slack.com/events
+const (
+	AppMention      = "app_mention"
+	MessageChannels = "message.channels"
+	ReactionAdded   = "reaction_added"
+	LinkShared      = "link_shared"
+)
+
+// EventDedupWindow is how long an event_id is remembered. Slack retries a
+// delivery up to 3 times over about 5 minutes.
+const EventDedupWindow = 10 * time.Minute
+
+// EventRequest is the outer payload of the Events API
+type EventRequest struct {
+	Token        string `json:"token"`
+	Type         string `json:"type"` // url_verification or event_callback
+	Challenge    string `json:"challenge"`
+	TeamId       string `json:"team_id"`
+	EnterpriseId string `json:"enterprise_id"`
+	APIAppId     string `json:"api_app_id"`
+	EventId      string `json:"event_id"`
+	EventTime    int64  `json:"event_time"`
+	Event        Event  `json:"event"`
+}
+
+// Event holds the fields of the event types slackcmd handles
+type Event struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype"`
+	User        string `json:"user"`
+	BotId       string `json:"bot_id"`
+	Text        string `json:"text"`
+	Channel     string `json:"channel"`
+	ChannelType string `json:"channel_type"`
+	Ts          string `json:"ts"`
+	ThreadTs    string `json:"thread_ts"`
+	EventTs     string `json:"event_ts"`
+
+	// reaction_added
+	Reaction string    `json:"reaction"`
+	ItemUser string    `json:"item_user"`
+	Item     EventItem `json:"item"`
+
+	// link_shared
+	MessageTs string `json:"message_ts"`
+	Links     []Link `json:"links"`
+
+	// tokens_revoked
+	Tokens RevokedTokens `json:"tokens"`
+}
+
+// RevokedTokens are the users whose tokens were revoked and the bot users
+// whose bot tokens were
+type RevokedTokens struct {
+	OAuth []string `json:"oauth"`
+	Bot   []string `json:"bot"`
+}
+
+// EventItem is the message a reaction was added to
+type EventItem struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	Ts      string `json:"ts"`
+}
+
+// Link is a URL shared in a message
+type Link struct {
+	Domain string `json:"domain"`
+	URL    string `json:"url"`
+}
+
+// Name returns the event name handlers are registered under. Message
+// events are named by the kind of conversation, Ex. message.channels
+func (e *Event) Name() string {
+	if e.Type != "message" {
+		return e.Type
+	}
+
+	switch e.ChannelType {
+	case "channel":
+		return MessageChannels
+	case "group":
+		return "message.groups"
+	case "im":
+		return "message.im"
+	case "mpim":
+		return "message.mpim"
+	}
+	return e.Type
+}
+
+// EventHandler handles an event. A returned payload is posted in reply to
+// the event's channel.
+type EventHandler func(ctx context.Context, req *EventRequest) (*CommandPayload, error)
+
+// EventReplier posts an EventHandler's payload. Ephemeral payloads, Ex.
+// errors, go to the event's user.
+type EventReplier func(ctx context.Context, req *EventRequest, cp *CommandPayload) error
+
+// eventHandler is an EventHandler and the config section of the command
+// that registered it
+type eventHandler struct {
+	section string
+	h       EventHandler
+}
+
+var (
+	eventMu       sync.RWMutex
+	eventHandlers = make(map[string][]eventHandler)
+)
+
+// HandleEvent registers a handler for an event name. Several commands can
+// handle the same event. section is as for HandleAction, "" for handlers
+// that don't belong to a command.
+func HandleEvent(section string, name string, h EventHandler) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+
+	if name == "" || h == nil {
+		panic("slack: HandleEvent needs an event name and handler")
+	}
+	eventHandlers[name] = append(eventHandlers[name], eventHandler{section, h})
+}
+
+func lookupEvent(name string) []eventHandler {
+	eventMu.RLock()
+	defer eventMu.RUnlock()
+	return eventHandlers[name]
+}
+
+// dedup remembers the event_ids seen within a window
+type dedup struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+}
+
+// first reports whether id hasn't been seen within the window
+func (d *dedup) first(id string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for k, t := range d.seen {
+		if now.Sub(t) > d.window {
+			delete(d.seen, k)
+		}
+	}
+
+	if _, ok := d.seen[id]; ok {
+		return false
+	}
+	d.seen[id] = now
+	return true
+}
+
+// EventsHandler serves the Events API request URL. It answers the
+// url_verification challenge, drops retried deliveries and runs the
+// handlers for each event in the background. Requests must be verified by
+// VerifyRequests or carry the legacy verification token. deps gives
+// handlers the Deps of their command and guard runs them, both may be nil.
+func EventsHandler(verificationToken string, reply EventReplier, deps DepsFunc, guard GuardFunc) http.Handler {
+	d := &dedup{window: EventDedupWindow, seen: make(map[string]time.Time)}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req EventRequest
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !IsVerified(r) && (verificationToken == "" || subtle.ConstantTimeCompare([]byte(req.Token), []byte(verificationToken)) != 1) {
+			http.Error(w, "Unauthorized", http.StatusForbidden)
+			return
+		}
+
+		switch req.Type {
+		case "url_verification":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(req.Challenge))
+			return
+		case "event_callback":
+		default:
+			slog.WarnContext(r.Context(), "slack: unsupported event request type", "type", req.Type)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// acknowledge within 3 seconds so Slack doesn't retry
+		w.WriteHeader(http.StatusOK)
+
+		if req.EventId != "" && !d.first(req.EventId, time.Now()) {
+			return
+		}
+
+		// don't answer other bots, including ourselves
+		if req.Event.BotId != "" || req.Event.Subtype == "bot_message" {
+			return
+		}
+
+		// correlates the logs of the event's handlers
+		background := logging.WithID(context.Background(), logging.NewID())
+		t := Trigger{TeamId: req.TeamId, ChannelId: req.Event.Channel, UserId: req.Event.User}
+		for _, h := range lookupEvent(req.Event.Name()) {
+			h := h
+			run := guard.guarded(h.section, t, func(ctx context.Context) (*CommandPayload, error) {
+				return h.h(ctx, &req)
+			})
+			Go(func() {
+				runEvent(withDeps(deps, h.section)(background), h.section, &req, run, reply)
+			})
+		}
+	})
+}
+
+// runEvent runs a guarded event handler of the command with section and
+// replies with its payload. An Error the user should see, Ex. being
+// throttled, is replied to them instead.
+func runEvent(ctx context.Context, section string, req *EventRequest, run func(ctx context.Context) (*CommandPayload, error), reply EventReplier) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	cp, err := run(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "slack: event error", "event", req.Event.Name(), "event_id", req.EventId, "err", err, stackAttr(err))
+
+		var e *Error
+		if !errors.As(err, &e) || req.Event.User == "" {
+			return
+		}
+		var command string
+		if section != "" {
+			command = commandName(section)
+		}
+		cp = ErrorPayload(command, err, logging.ID(ctx))
+	}
+
+	if cp == nil || reply == nil {
+		return
+	}
+
+	err = reply(ctx, req, cp)
+	if err != nil {
+		slog.ErrorContext(ctx, "slack: event reply error", "event", req.Event.Name(), "event_id", req.EventId, "err", err)
+	}
+}
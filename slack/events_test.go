@@ -0,0 +1,226 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postEvent(h http.Handler, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/events", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestEventsURLVerification(t *testing.T) {
+	h := EventsHandler("secret", nil, nil, nil)
+
+	w := postEvent(h, `{"token":"secret","type":"url_verification","challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`)
+	if w.Body.String() != "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P" {
+		t.Errorf("Test errored. Challenge should be echoed but body is %q", w.Body.String())
+	}
+
+	w = postEvent(h, `{"token":"wrong","type":"url_verification","challenge":"abc"}`)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Test errored. Status with wrong token should be %v but is %v", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestEventsDispatch(t *testing.T) {
+	isolate(t)
+	handled := make(chan string, 10)
+	HandleEvent("", MessageChannels, func(ctx context.Context, req *EventRequest) (*CommandPayload, error) {
+		handled <- req.EventId
+		return &CommandPayload{Text: "echo " + req.Event.Text}, nil
+	})
+
+	replies := make(chan string, 10)
+	h := EventsHandler("secret", func(ctx context.Context, req *EventRequest, cp *CommandPayload) error {
+		replies <- cp.Text
+		return nil
+	}, nil, nil)
+
+	message := `{"token":"secret","type":"event_callback","event_id":"Ev1","event":{"type":"message","channel_type":"channel","channel":"C1","text":"hi"}}`
+	postEvent(h, message)
+	// retried delivery of the same event
+	postEvent(h, message)
+	// bot messages and other channel types are ignored
+	postEvent(h, `{"token":"secret","type":"event_callback","event_id":"Ev2","event":{"type":"message","channel_type":"channel","bot_id":"B1","text":"hi"}}`)
+	postEvent(h, `{"token":"secret","type":"event_callback","event_id":"Ev3","event":{"type":"message","channel_type":"im","text":"hi"}}`)
+
+	select {
+	case id := <-handled:
+		if id != "Ev1" {
+			t.Errorf("Test errored. Event should be %v but is %v", "Ev1", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Test errored. Event was never handled")
+	}
+
+	select {
+	case text := <-replies:
+		if text != "echo hi" {
+			t.Errorf("Test errored. Reply should be %q but is %q", "echo hi", text)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Test errored. Reply was never sent")
+	}
+
+	select {
+	case id := <-handled:
+		t.Errorf("Test errored. Only one event should be handled but %v was too", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDedupWindow(t *testing.T) {
+	d := &dedup{window: time.Minute, seen: make(map[string]time.Time)}
+	now := time.Now()
+
+	tests := []struct {
+		id    string
+		at    time.Time
+		first bool
+	}{
+		{"Ev1", now, true},
+		{"Ev1", now.Add(30 * time.Second), false},
+		{"Ev2", now.Add(30 * time.Second), true},
+		{"Ev1", now.Add(2 * time.Minute), true},
+	}
+
+	for _, test := range tests {
+		if first := d.first(test.id, test.at); first != test.first {
+			t.Errorf("Test errored. first(%v) should be %v but is %v", test.id, test.first, first)
+		}
+	}
+}
+
+func TestEventsGuard(t *testing.T) {
+	isolate(t)
+	handled := make(chan string, 10)
+	HandleEvent("test", AppMention, func(ctx context.Context, req *EventRequest) (*CommandPayload, error) {
+		handled <- req.EventId
+		panic("boom")
+	})
+
+	type guarded struct {
+		section string
+		trigger Trigger
+		err     error
+	}
+	guards := make(chan guarded, 10)
+	guard := func(ctx context.Context, section string, tr Trigger, run func(ctx context.Context) (*CommandPayload, error)) (*CommandPayload, error) {
+		if tr.UserId == "U2" {
+			guards <- guarded{section, tr, nil}
+			return nil, errors.New("turned off")
+		}
+		cp, err := run(ctx)
+		guards <- guarded{section, tr, err}
+		return cp, err
+	}
+	h := EventsHandler("secret", nil, nil, guard)
+
+	tests := []struct {
+		eventID  string
+		user     string
+		handled  bool
+		panicked bool
+	}{
+		{"Ev1", "U1", true, true},
+		{"Ev2", "U2", false, false},
+	}
+
+	for _, test := range tests {
+		postEvent(h, `{"token":"secret","type":"event_callback","team_id":"T1","event_id":"`+test.eventID+`",`+
+			`"event":{"type":"app_mention","channel":"C1","user":"`+test.user+`","text":"<@B1> hi"}}`)
+
+		select {
+		case g := <-guards:
+			expected := Trigger{TeamId: "T1", ChannelId: "C1", UserId: test.user}
+			if g.section != "test" || g.trigger != expected {
+				t.Errorf("Test errored. Guard should get %v %+v but got %v %+v", "test", expected, g.section, g.trigger)
+			}
+			var pe *PanicError
+			if panicked := errors.As(g.err, &pe); panicked != test.panicked {
+				t.Errorf("Test errored. %v panic should be %v but is %v", test.eventID, test.panicked, panicked)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Test errored. %v never went through the guard", test.eventID)
+		}
+
+		select {
+		case <-handled:
+			if !test.handled {
+				t.Errorf("Test errored. %v shouldn't be handled", test.eventID)
+			}
+		default:
+			if test.handled {
+				t.Errorf("Test errored. %v should be handled", test.eventID)
+			}
+		}
+	}
+}
+
+func TestEventsErrorReply(t *testing.T) {
+	isolate(t)
+	HandleEvent("test", AppMention, func(ctx context.Context, req *EventRequest) (*CommandPayload, error) {
+		if req.Event.User == "U1" {
+			return nil, errors.New("boom")
+		}
+		return nil, Errorf(BadInput, "There's no board named %q.", "Dev")
+	})
+	guard := func(ctx context.Context, section string, tr Trigger, run func(ctx context.Context) (*CommandPayload, error)) (*CommandPayload, error) {
+		if tr.UserId == "U2" {
+			return nil, Errorf(BadInput, "Slow down, try again in 60s.")
+		}
+		return run(ctx)
+	}
+
+	type replied struct {
+		user string
+		cp   *CommandPayload
+	}
+	replies := make(chan replied, 10)
+	h := EventsHandler("secret", func(ctx context.Context, req *EventRequest, cp *CommandPayload) error {
+		replies <- replied{req.Event.User, cp}
+		return nil
+	}, nil, guard)
+
+	tests := []struct {
+		user     string
+		expected string
+	}{
+		{"U1", ""}, // only errors meant for the user are replied
+		{"U2", "Slow down, try again in 60s."},
+		{"U3", "There's no board named \"Dev\"."},
+	}
+
+	for i, test := range tests {
+		postEvent(h, `{"token":"secret","type":"event_callback","team_id":"T1","event_id":"Ev`+test.user+`",`+
+			`"event":{"type":"app_mention","channel":"C1","user":"`+test.user+`","text":"<@B1> hi"}}`)
+
+		select {
+		case r := <-replies:
+			if test.expected == "" {
+				t.Errorf("Test errored. Case %v shouldn't reply but replied %q", i, r.cp.Text)
+				continue
+			}
+			if !strings.HasPrefix(r.cp.Text, test.expected) {
+				t.Errorf("Test errored. Case %v reply should start with %q but is %q", i, test.expected, r.cp.Text)
+			}
+			if r.user != test.user || r.cp.ResponseType != ResponseEphemeral {
+				t.Errorf("Test errored. Case %v reply should be ephemeral to %v but is %q to %v", i, test.user, r.cp.ResponseType, r.user)
+			}
+		case <-time.After(200 * time.Millisecond):
+			if test.expected != "" {
+				t.Errorf("Test errored. Case %v should reply %q", i, test.expected)
+			}
+		}
+	}
+}
@@ -1,12 +1,51 @@
 /*
-	Boolean only flags for Slack Slash Commands
+Flags for Slack Slash Commands
+
+Boolean flags are switches. String, int, duration, date and enum
+flags take a value either attached with "=" (--limit=5) or as the
+word after the flag (--limit 5).
 */
 package slack
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FlagType is the kind of value a flag accepts
+type FlagType int
+
+const (
+	BoolFlag FlagType = iota
+	StringFlag
+	IntFlag
+	DurationFlag
+	DateFlag
+	EnumFlag
 )
 
+// DateLayout is the format DateFlag values are written in
+const DateLayout = "2006-01-02"
+
+func (t FlagType) String() string {
+	switch t {
+	case StringFlag:
+		return "string"
+	case IntFlag:
+		return "int"
+	case DurationFlag:
+		return "duration"
+	case DateFlag:
+		return "YYYY-MM-DD"
+	case EnumFlag:
+		return "enum"
+	}
+	return "bool"
+}
+
 type FlagSet struct {
 	Flags []Flag
 	Usage string // help message
@@ -36,40 +75,336 @@ type Flag struct {
 	Name      string // full name
 	ShortName string // single letter name
 	Usage     string // help message
-	Callback  func()
+	Callback  func() // called when a BoolFlag is set
+
+	Type     FlagType
+	Default  string             // value used when the flag isn't passed
+	Required bool               // the flag must be passed
+	Options  []string           // allowed values of an EnumFlag
+	Validate func(string) error // optional extra check of the value
+
+	value string
+	set   bool
 }
 
 func (f Flag) String() string {
+	name := "--" + f.Name
+	if f.ShortName != "" {
+		name += fmt.Sprintf(" (-%v)", f.ShortName)
+	}
+
+	switch f.Type {
+	case BoolFlag:
+	case EnumFlag:
+		name += fmt.Sprintf(" <%v>", strings.Join(f.Options, "|"))
+	default:
+		name += fmt.Sprintf(" <%v>", f.Type)
+	}
+
+	usage := f.Usage
+	if f.Required {
+		usage += " (required)"
+	} else if f.Default != "" {
+		usage += fmt.Sprintf(" (default %v)", f.Default)
+	}
+
 	return fmt.Sprintf(
-		"• --%v (-%v): %v \n",
-		f.Name,
-		f.ShortName,
-		f.Usage,
+		"• %v: %v \n",
+		name,
+		usage,
 	)
 }
 
+// Value returns the value passed for the flag or its default
+func (f *Flag) Value() string {
+	if f.set {
+		return f.value
+	}
+	return f.Default
+}
+
+// IsSet reports whether the flag was passed. It is false for a nil Flag
+// so the result of Lookup can be checked directly.
+func (f *Flag) IsSet() bool {
+	return f != nil && f.set
+}
+
+// check validates a value against the flag's type, options and Validate
+func (f *Flag) check(value string) error {
+	var err error
+
+	switch f.Type {
+	case BoolFlag:
+		_, err = strconv.ParseBool(value)
+	case IntFlag:
+		_, err = strconv.Atoi(value)
+	case DurationFlag:
+		_, err = time.ParseDuration(value)
+	case DateFlag:
+		_, err = time.ParseInLocation(DateLayout, value, time.Local)
+	case EnumFlag:
+		err = errors.New("must be one of " + strings.Join(f.Options, ", "))
+		for _, o := range f.Options {
+			if strings.EqualFold(o, value) {
+				err = nil
+			}
+		}
+	}
+
+	if err == nil && f.Validate != nil {
+		err = f.Validate(value)
+	}
+
+	if err != nil {
+		if f.Type != EnumFlag && f.Validate == nil {
+			return fmt.Errorf("invalid value %q for --%v: expected %v", value, f.Name, f.Type)
+		}
+		return fmt.Errorf("invalid value %q for --%v: %v", value, f.Name, err)
+	}
+
+	return nil
+}
+
 func SetFlag(fs *FlagSet, name string, shortname string, usage string, callback func()) {
-	f := Flag{name, shortname, usage, callback}
+	f := Flag{
+		Name:      name,
+		ShortName: shortname,
+		Usage:     usage,
+		Callback:  callback,
+	}
 
 	fs.addFlag(f)
 
 }
 
-func ParseFlags(fs *FlagSet, flags []string) (h bool, s string) {
+// AddFlag adds a flag of any type to the FlagSet. It panics if the flag's
+// default is not a valid value so mistakes are caught at startup.
+func AddFlag(fs *FlagSet, f Flag) {
+	if f.Default != "" {
+		if err := f.check(f.Default); err != nil {
+			panic("slack: AddFlag default " + err.Error())
+		}
+	}
+
+	fs.addFlag(f)
+}
+
+// SetStringFlag adds a flag that takes any string value
+func SetStringFlag(fs *FlagSet, name string, shortname string, usage string, value string) {
+	AddFlag(fs, Flag{Name: name, ShortName: shortname, Usage: usage, Type: StringFlag, Default: value})
+}
+
+// SetIntFlag adds a flag that takes an integer value
+func SetIntFlag(fs *FlagSet, name string, shortname string, usage string, value int) {
+	AddFlag(fs, Flag{Name: name, ShortName: shortname, Usage: usage, Type: IntFlag, Default: strconv.Itoa(value)})
+}
+
+// SetDurationFlag adds a flag that takes a duration such as "1h30m"
+func SetDurationFlag(fs *FlagSet, name string, shortname string, usage string, value time.Duration) {
+	AddFlag(fs, Flag{Name: name, ShortName: shortname, Usage: usage, Type: DurationFlag, Default: value.String()})
+}
+
+// SetDateFlag adds a flag that takes a date written as DateLayout.
+// An empty value means no default.
+func SetDateFlag(fs *FlagSet, name string, shortname string, usage string, value string) {
+	AddFlag(fs, Flag{Name: name, ShortName: shortname, Usage: usage, Type: DateFlag, Default: value})
+}
+
+// SetEnumFlag adds a flag that only accepts one of options
+func SetEnumFlag(fs *FlagSet, name string, shortname string, usage string, value string, options ...string) {
+	AddFlag(fs, Flag{Name: name, ShortName: shortname, Usage: usage, Type: EnumFlag, Default: value, Options: options})
+}
+
+// Lookup returns the flag with the full or short name
+func (fs *FlagSet) Lookup(name string) *Flag {
+	if fs == nil {
+		return nil
+	}
+	for i := range fs.Flags {
+		if name == fs.Flags[i].Name || (name == fs.Flags[i].ShortName && name != "") {
+			return &fs.Flags[i]
+		}
+	}
+	return nil
+}
+
+// Value returns the string value of a flag or "" if there is no such flag
+func (fs *FlagSet) Value(name string) string {
+	f := fs.Lookup(name)
+	if f == nil {
+		return ""
+	}
+	return f.Value()
+}
+
+// Bool returns the value of a BoolFlag
+func (fs *FlagSet) Bool(name string) bool {
+	b, _ := strconv.ParseBool(fs.Value(name))
+	return b
+}
+
+// Int returns the value of an IntFlag
+func (fs *FlagSet) Int(name string) int {
+	i, _ := strconv.Atoi(fs.Value(name))
+	return i
+}
+
+// Duration returns the value of a DurationFlag
+func (fs *FlagSet) Duration(name string) time.Duration {
+	d, _ := time.ParseDuration(fs.Value(name))
+	return d
+}
+
+// Date returns the value of a DateFlag as midnight local time. It is the
+// zero time if the flag has no value.
+func (fs *FlagSet) Date(name string) time.Time {
+	d, _ := time.ParseInLocation(DateLayout, fs.Value(name), time.Local)
+	return d
+}
+
+// Parse sets the flags returned by Separate or SeparateFlags. It returns
+// true if help was requested and an error if a flag is unknown, a value is
+// invalid or a required flag is missing.
+func (fs *FlagSet) Parse(flags []string) (help bool, err error) {
+	return fs.parse(flags, true)
+}
+
+func (fs *FlagSet) parse(flags []string, strict bool) (help bool, err error) {
 	for _, flag := range flags {
+		name, value, hasValue := strings.Cut(flag, "=")
+
 		// Test for help command
-		if flag == "help" || flag == "h" {
-			return true, fmt.Sprint(fs)
+		if name == "help" || name == "h" {
+			return true, nil
 		}
 
 		// check each flag passed with all registerd
-		for _, fsFlag := range fs.Flags {
-			if flag == fsFlag.Name || flag == fsFlag.ShortName {
-				fsFlag.Callback()
+		f := fs.Lookup(name)
+		if f == nil && strict {
+			return false, fmt.Errorf("unknown flag --%v", name)
+		}
+		if f == nil {
+			continue
+		}
+
+		if f.Type == BoolFlag && !hasValue {
+			value = "true"
+		} else if !hasValue {
+			return false, fmt.Errorf("missing value for --%v", f.Name)
+		}
+
+		err := f.check(value)
+		if err != nil {
+			return false, err
+		}
+
+		// store enum values as declared
+		for _, o := range f.Options {
+			if strings.EqualFold(o, value) {
+				value = o
 			}
 		}
+
+		f.value = value
+		f.set = true
+
+		if f.Type == BoolFlag && f.Callback != nil && fs.Bool(f.Name) {
+			f.Callback()
+		}
+	}
+
+	for _, f := range fs.Flags {
+		if f.Required && !f.set {
+			return false, fmt.Errorf("missing required flag --%v", f.Name)
+		}
+	}
+
+	return false, nil
+}
+
+// ParseFlags parses flags and returns true with the text to send back when
+// help was requested or the flags are invalid. Unlike Parse unknown flags
+// are ignored.
+func ParseFlags(fs *FlagSet, flags []string) (h bool, s string) {
+	help, err := fs.parse(flags, false)
+	if err != nil {
+		return true, fmt.Sprintf("%v\n%v", err, fs)
+	}
+
+	if help {
+		return true, fmt.Sprint(fs)
 	}
 
 	// Return string without flags
 	return false, ""
 }
+
+// Separate splits slash command text into arguments and flags like
+// SeparateFlags. Flags that take a value also consume the next word
+// unless the value was attached with "=", so values may start with a dash
+// as long as they aren't flags themselves. Quoted words are never flags and
+// every word after "--" is an argument.
+// Ex. `"Design Team" --limit 5` returns ["Design Team"], ["limit=5"]
+func (fs *FlagSet) Separate(t string) (args []string, flags []string, err error) {
+	tokens, err := Tokenize(t)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return separate(fs, tokens)
+}
+
+func separate(fs *FlagSet, tokens []Token) (args []string, flags []string, err error) {
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token.Quoted {
+			args = append(args, token.Value)
+			continue
+		}
+
+		// "--" ends the flags
+		if token.Value == "--" {
+			for _, t := range tokens[i+1:] {
+				args = append(args, t.Value)
+			}
+			break
+		}
+
+		name, ok := trimFlag(token.Value)
+		if !ok || name == "" {
+			args = append(args, token.Value)
+			continue
+		}
+
+		f := fs.Lookup(name)
+		if f != nil && f.Type != BoolFlag && i+1 < len(tokens) {
+			if isFlag(fs, tokens[i+1]) {
+				return nil, nil, fmt.Errorf("flag needs an argument: --%v", f.Name)
+			}
+			i++
+			name += "=" + tokens[i].Value
+		}
+
+		flags = append(flags, name)
+	}
+
+	return args, flags, nil
+}
+
+// isFlag reports whether a token is "--", a flag of fs or a long flag.
+// Other words starting with a dash, Ex. "-5", can be values.
+func isFlag(fs *FlagSet, token Token) bool {
+	if token.Quoted {
+		return false
+	}
+	if token.Value == "--" {
+		return true
+	}
+
+	name, ok := trimFlag(token.Value)
+	if !ok || name == "" {
+		return false
+	}
+	name, _, _ = strings.Cut(name, "=")
+	return fs.Lookup(name) != nil || !strings.HasPrefix(token.Value, "-") || strings.HasPrefix(token.Value, "--")
+}
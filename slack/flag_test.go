@@ -3,7 +3,9 @@ package slack
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestVar(t *testing.T) {
@@ -71,3 +73,104 @@ func TestParseFlags(t *testing.T) {
 	}
 
 }
+
+func TestTypedFlags(t *testing.T) {
+	newFlagSet := func() *FlagSet {
+		fs := &FlagSet{Usage: "/conference help: Schedule for FG Conference room"}
+		SetFlag(fs, "channel", "c", "channel flag usage", func() {})
+		SetIntFlag(fs, "limit", "l", "Maximum number of events", 10)
+		SetDurationFlag(fs, "length", "", "Length of the booking", 30*time.Minute)
+		SetDateFlag(fs, "date", "d", "Day to show", "")
+		SetEnumFlag(fs, "room", "r", "Room to show", "conference", "conference", "boardroom")
+		AddFlag(fs, Flag{Name: "title", ShortName: "t", Usage: "Booking title", Type: StringFlag, Required: true})
+		return fs
+	}
+
+	tests := []struct {
+		text   string
+		args   []string
+		failed bool
+	}{
+		{"book -t standup --limit 5 --length=1h -d 2026-10-20 --room BOARDROOM -c", []string{"book"}, false},
+		{"book now -t standup", []string{"book", "now"}, false},
+		{"book --limit five -t standup", []string{"book"}, true},
+		{"book --room kitchen -t standup", []string{"book"}, true},
+		{"book --date 10/20/2026 -t standup", []string{"book"}, true},
+		{"book", []string{"book"}, true},
+		{"book -t", []string{"book"}, true},
+		{"book -t standup --attendees 4", []string{"book", "4"}, true},
+	}
+
+	for _, test := range tests {
+		fs := newFlagSet()
+		args, flags, err := fs.Separate(test.text)
+		if err != nil {
+			t.Errorf("Test errored. Separate %q returned %v", test.text, err)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("Test errored. Args should be %v but are %v", test.args, args)
+		}
+
+		help, err := fs.Parse(flags)
+		if help {
+			t.Errorf("Test errored. %q should not request help", test.text)
+		}
+		if (err != nil) != test.failed {
+			t.Errorf("Test errored. %q should fail: %v but returned %v", test.text, test.failed, err)
+		}
+	}
+
+	// values are surfaced with the typed getters
+	fs := newFlagSet()
+	_, flags, _ := fs.Separate("book -t standup --limit 5 --length=1h -d 2026-10-20 --room BOARDROOM -c")
+	if _, err := fs.Parse(flags); err != nil {
+		t.Fatal("Test errored. Parse returned", err)
+	}
+
+	if fs.Value("title") != "standup" {
+		t.Errorf("Test errored. title should be standup but is %v", fs.Value("title"))
+	}
+	if fs.Int("limit") != 5 {
+		t.Errorf("Test errored. limit should be 5 but is %v", fs.Int("limit"))
+	}
+	if fs.Duration("length") != time.Hour {
+		t.Errorf("Test errored. length should be 1h but is %v", fs.Duration("length"))
+	}
+	if d := fs.Date("date"); d.Year() != 2026 || d.Month() != time.October || d.Day() != 20 {
+		t.Errorf("Test errored. date should be 2026-10-20 but is %v", d)
+	}
+	if fs.Value("room") != "boardroom" {
+		t.Errorf("Test errored. room should be boardroom but is %v", fs.Value("room"))
+	}
+	if !fs.Bool("channel") {
+		t.Error("Test errored. channel should be set")
+	}
+
+	// defaults are used when flags aren't passed
+	fs = newFlagSet()
+	fs.Parse([]string{"title=standup"})
+	if fs.Int("limit") != 10 || fs.Duration("length") != 30*time.Minute || fs.Value("room") != "conference" {
+		t.Errorf("Test errored. Defaults should be 10, 30m and conference but are %v, %v and %v", fs.Int("limit"), fs.Duration("length"), fs.Value("room"))
+	}
+	if !fs.Date("date").IsZero() || fs.Bool("channel") {
+		t.Error("Test errored. date and channel should not be set")
+	}
+
+	// help lists the value each flag takes
+	help := fmt.Sprint(fs)
+	for _, s := range []string{"--limit (-l) <int>: Maximum number of events (default 10)", "--room (-r) <conference|boardroom>", "--title (-t) <string>: Booking title (required)", "--length <duration>"} {
+		if !strings.Contains(help, s) {
+			t.Errorf("Test errored. Help should contain %q but is %v", s, help)
+		}
+	}
+}
+
+func TestAddFlagInvalidDefault(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Test errored. AddFlag should panic for an invalid default")
+		}
+	}()
+
+	SetEnumFlag(&FlagSet{}, "room", "r", "Room to show", "kitchen", "conference", "boardroom")
+}
@@ -0,0 +1,431 @@
+package slack
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jesselucas/slackcmd/logging"
+	"github.com/jesselucas/slackcmd/metrics"
+)
+
+// Interaction payload types - https://api.slack.com/reference/interaction-payloads
+const (
+	BlockActions   = "block_actions"
+	ViewSubmission = "view_submission"
+	ViewClosed     = "view_closed"
+	MessageAction  = "message_action"
+	Shortcut       = "shortcut"
+)
+
+type Team struct {
+	Id     string `json:"id"`
+	Domain string `json:"domain"`
+}
+
+type Enterprise struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// User is the user in an interaction or returned by users.info
+type User struct {
+	Id       string      `json:"id"`
+	Name     string      `json:"name"`
+	Username string      `json:"username"`
+	TeamId   string      `json:"team_id"`
+	RealName string      `json:"real_name"`
+	TZ       string      `json:"tz"`
+	IsBot    bool        `json:"is_bot"`
+	Profile  UserProfile `json:"profile"`
+}
+
+type UserProfile struct {
+	DisplayName string `json:"display_name"`
+	RealName    string `json:"real_name"`
+	Email       string `json:"email"`
+}
+
+// Channel is the channel in an interaction or returned by conversations.info
+type Channel struct {
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	IsChannel  bool   `json:"is_channel"`
+	IsPrivate  bool   `json:"is_private"`
+	IsIm       bool   `json:"is_im"`
+	IsArchived bool   `json:"is_archived"`
+}
+
+// Interaction is the payload Slack sends when a user clicks a button,
+// picks from a menu, submits a modal or uses a shortcut
+type Interaction struct {
+	Type        string          `json:"type"`
+	Token       string          `json:"token"`
+	APIAppId    string          `json:"api_app_id"`
+	Team        Team            `json:"team"`
+	Enterprise  *Enterprise     `json:"enterprise"`
+	User        User            `json:"user"`
+	Channel     Channel         `json:"channel"`
+	TriggerId   string          `json:"trigger_id"`
+	ResponseURL string          `json:"response_url"`
+	CallbackId  string          `json:"callback_id"`
+	Actions     []Action        `json:"actions"`
+	View        *View           `json:"view"`
+	Message     json.RawMessage `json:"message"`
+	IsCleared   bool            `json:"is_cleared"`
+
+	// Client calls the Web API with the workspace's bot token, nil when
+	// there isn't one
+	Client *Client `json:"-"`
+}
+
+// Action is an element a user interacted with, or the value of an input
+// element in ViewState
+type Action struct {
+	Type           string  `json:"type"`
+	ActionId       string  `json:"action_id"`
+	BlockId        string  `json:"block_id"`
+	Value          string  `json:"value"`
+	SelectedOption *Option `json:"selected_option"`
+	SelectedDate   string  `json:"selected_date"`
+	ActionTs       string  `json:"action_ts"`
+}
+
+// SelectedValue returns the value of a button or input, the option picked
+// from a menu or the date picked
+func (a Action) SelectedValue() string {
+	switch {
+	case a.SelectedOption != nil:
+		return a.SelectedOption.Value
+	case a.SelectedDate != "":
+		return a.SelectedDate
+	}
+	return a.Value
+}
+
+// ActionHandler handles a block_actions payload for one action_id. A
+// returned payload is posted to the interaction's response_url.
+type ActionHandler func(ctx context.Context, in *Interaction, a *Action) (*CommandPayload, error)
+
+// CallbackHandler handles view_submission, view_closed, message_action and
+// shortcut payloads for one callback_id. A returned payload is posted to
+// the interaction's response_url when it has one. For view_submission
+// returning ValidationErrors shows them in the modal and a payload with a
+// Modal replaces the modal.
+type CallbackHandler func(ctx context.Context, in *Interaction) (*CommandPayload, error)
+
+// actionHandler is an ActionHandler and the config section of the command
+// that registered it
+type actionHandler struct {
+	section string
+	h       ActionHandler
+}
+
+type callbackHandler struct {
+	section string
+	h       CallbackHandler
+}
+
+var (
+	interactionMu sync.RWMutex
+	actions       = make(map[string]actionHandler)
+	callbacks     = make(map[string]callbackHandler)
+)
+
+// HandleAction registers the handler for an action_id. section is the
+// ConfigSection of the command registering it, whose Deps the handler gets
+// from DepsFrom. Like Register it panics if the action_id is already
+// handled.
+func HandleAction(section string, actionID string, h ActionHandler) {
+	interactionMu.Lock()
+	defer interactionMu.Unlock()
+
+	if actionID == "" || h == nil {
+		panic("slack: HandleAction needs an action_id and handler")
+	}
+	if _, dup := actions[actionID]; dup {
+		panic("slack: HandleAction called twice for " + actionID)
+	}
+	actions[actionID] = actionHandler{section, h}
+}
+
+// HandleCallback registers the handler for a callback_id of a modal,
+// message shortcut or global shortcut. section is as for HandleAction.
+func HandleCallback(section string, callbackID string, h CallbackHandler) {
+	interactionMu.Lock()
+	defer interactionMu.Unlock()
+
+	if callbackID == "" || h == nil {
+		panic("slack: HandleCallback needs a callback_id and handler")
+	}
+	if _, dup := callbacks[callbackID]; dup {
+		panic("slack: HandleCallback called twice for " + callbackID)
+	}
+	callbacks[callbackID] = callbackHandler{section, h}
+}
+
+func lookupAction(actionID string) (actionHandler, bool) {
+	interactionMu.RLock()
+	defer interactionMu.RUnlock()
+	h, ok := actions[actionID]
+	return h, ok
+}
+
+func lookupCallback(callbackID string) (callbackHandler, bool) {
+	interactionMu.RLock()
+	defer interactionMu.RUnlock()
+	h, ok := callbacks[callbackID]
+	return h, ok
+}
+
+// ParseInteraction decodes the payload form field of an interaction request
+func ParseInteraction(r *http.Request) (*Interaction, error) {
+	err := r.ParseForm()
+	if err != nil {
+		return nil, err
+	}
+
+	payload := r.PostForm.Get("payload")
+	if payload == "" {
+		return nil, &FieldError{"payload", "missing"}
+	}
+
+	var in Interaction
+	err = json.Unmarshal([]byte(payload), &in)
+	if err != nil {
+		return nil, &FieldError{"payload", err.Error()}
+	}
+
+	if in.ResponseURL != "" && !isCallbackURL(in.ResponseURL) {
+		return nil, &FieldError{"response_url", fmt.Sprintf("%q is not an https Slack URL", in.ResponseURL)}
+	}
+
+	return &in, nil
+}
+
+// InteractionHandler serves Slack's interactivity request URL. Requests must
+// be verified by VerifyRequests or carry the legacy verification token.
+// clients finds the Interaction.Client for the workspace, deps the Deps of
+// the handler's command and guard runs the handler. All may be nil. client
+// posts to response_urls, http.DefaultClient when nil.
+// Slack expects an answer within 3 seconds, so handlers run in the
+// background except for view_submission which answers the modal when it's
+// done within submissionTimeout.
+func InteractionHandler(verificationToken string, clients ClientFunc, deps DepsFunc, guard GuardFunc, client *http.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		in, err := ParseInteraction(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !IsVerified(r) && (verificationToken == "" || subtle.ConstantTimeCompare([]byte(in.Token), []byte(verificationToken)) != 1) {
+			http.Error(w, "Unauthorized", http.StatusForbidden)
+			return
+		}
+
+		// correlates the logs of the interaction and its background work
+		id := logging.NewID()
+		ctx := logging.WithID(r.Context(), id)
+		background := logging.WithID(context.Background(), id)
+		w.Header().Set(logging.Header, id)
+
+		if clients != nil {
+			var enterpriseID string
+			if in.Enterprise != nil {
+				enterpriseID = in.Enterprise.Id
+			}
+			in.Client, err = clients(ctx, enterpriseID, in.Team.Id)
+			if err != nil {
+				slog.ErrorContext(ctx, "slack: finding client", "team", in.Team.Id, "err", err)
+			}
+		}
+
+		t := Trigger{TeamId: in.Team.Id, ChannelId: in.Channel.Id, UserId: in.User.Id}
+
+		switch in.Type {
+		case BlockActions:
+			w.WriteHeader(http.StatusOK)
+			for i := range in.Actions {
+				a := &in.Actions[i]
+				h, ok := lookupAction(a.ActionId)
+				if !ok {
+					slog.WarnContext(ctx, "slack: no handler for action", "action_id", a.ActionId)
+					continue
+				}
+				Go(func() {
+					runInteraction(withDeps(deps, h.section)(background), reply{in, h.section, client}, guard.guarded(h.section, t, func(ctx context.Context) (*CommandPayload, error) {
+						return h.h(ctx, in, a)
+					}))
+				})
+			}
+
+		case ViewSubmission, ViewClosed:
+			if in.View == nil {
+				http.Error(w, "missing view", http.StatusBadRequest)
+				return
+			}
+			h, ok := lookupCallback(in.View.CallbackId)
+			if !ok {
+				slog.WarnContext(ctx, "slack: no handler for view", "callback_id", in.View.CallbackId)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			hctx := withDeps(deps, h.section)(background)
+			run := guard.guarded(h.section, t, func(ctx context.Context) (*CommandPayload, error) {
+				return h.h(ctx, in)
+			})
+			if in.Type == ViewClosed {
+				w.WriteHeader(http.StatusOK)
+				Go(func() { runInteraction(hctx, reply{in, h.section, client}, run) })
+				return
+			}
+			submitView(hctx, w, reply{in, h.section, client}, run)
+
+		case MessageAction, Shortcut:
+			w.WriteHeader(http.StatusOK)
+			h, ok := lookupCallback(in.CallbackId)
+			if !ok {
+				slog.WarnContext(ctx, "slack: no handler for shortcut", "callback_id", in.CallbackId)
+				return
+			}
+			Go(func() {
+				runInteraction(withDeps(deps, h.section)(background), reply{in, h.section, client}, guard.guarded(h.section, t, func(ctx context.Context) (*CommandPayload, error) {
+					return h.h(ctx, in)
+				}))
+			})
+
+		default:
+			slog.WarnContext(ctx, "slack: unsupported interaction type", "type", in.Type)
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+func writeSubmission(w http.ResponseWriter, sr *submissionResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sr)
+}
+
+// withDeps returns a function adding the Deps of section to a context
+func withDeps(deps DepsFunc, section string) func(ctx context.Context) context.Context {
+	return func(ctx context.Context) context.Context {
+		if deps == nil || section == "" {
+			return ctx
+		}
+		return WithDeps(ctx, deps(section))
+	}
+}
+
+// submissionTimeout is how long a view_submission handler has to answer the
+// modal, Slack drops answers after 3 seconds
+var submissionTimeout = 2500 * time.Millisecond
+
+// submitView runs a view_submission handler in the background. When
+// it's done within submissionTimeout its errors are shown in the modal or
+// its Modal replaces it, otherwise the modal is closed and the handler
+// finishes in the background.
+func submitView(ctx context.Context, w http.ResponseWriter, rp reply, run func(ctx context.Context) (*CommandPayload, error)) {
+	type result struct {
+		cp  *CommandPayload
+		err error
+	}
+	done := make(chan result)
+	late := make(chan struct{})
+	Go(func() {
+		hctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+		cp, err := run(hctx)
+
+		select {
+		case done <- result{cp, err}:
+		case <-late:
+			rp.respond(ctx, cp, err)
+		}
+	})
+
+	timer := time.NewTimer(submissionTimeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		if verrs, ok := r.err.(ValidationErrors); ok {
+			writeSubmission(w, &submissionResponse{ResponseAction: "errors", Errors: verrs})
+			return
+		}
+		if r.err == nil && r.cp != nil && r.cp.Modal != nil {
+			writeSubmission(w, &submissionResponse{ResponseAction: "update", View: r.cp.Modal})
+			return
+		}
+		if r.err != nil || r.cp != nil {
+			Go(func() { rp.respond(ctx, r.cp, r.err) })
+		}
+
+	case <-timer.C:
+		close(late)
+		slog.WarnContext(ctx, "slack: view submission still running, closing the modal", "callback_id", rp.in.View.CallbackId)
+	}
+
+	// answering with an empty 200 closes the modal
+	w.WriteHeader(http.StatusOK)
+}
+
+// runInteraction runs a guarded handler after the request was acknowledged
+// and responds with its payload
+func runInteraction(ctx context.Context, rp reply, run func(ctx context.Context) (*CommandPayload, error)) {
+	hctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	cp, err := run(hctx)
+	rp.respond(ctx, cp, err)
+}
+
+// reply is where the payload of the handler an interaction ran goes
+type reply struct {
+	in      *Interaction
+	section string       // of the handler's command
+	client  *http.Client // posts to the response_url
+}
+
+// respond posts the payload of an interaction's handler to its
+// response_url. An error is reported to the user instead, by DM when
+// there's no response_url, Ex. for a modal.
+func (rp reply) respond(ctx context.Context, cp *CommandPayload, err error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	in := rp.in
+
+	failed := err != nil
+	if failed {
+		slog.ErrorContext(ctx, "slack: interaction error", "type", in.Type, "kind", KindOf(err).String(), "err", err, stackAttr(err))
+		cp = ErrorPayload("", err, logging.ID(ctx))
+	}
+	if cp == nil {
+		return
+	}
+
+	switch {
+	case in.ResponseURL != "":
+		r := NewResponder(in.ResponseURL)
+		r.Client = rp.client
+		err = r.SendContext(ctx, cp)
+	case failed && in.Client != nil:
+		_, _, err = in.Client.PostMessage(ctx, in.User.Id, cp)
+	case failed:
+		slog.WarnContext(ctx, "slack: no way to tell the user about the error", "type", in.Type, "user", in.User.Id)
+		return
+	default:
+		return
+	}
+	if err != nil {
+		metrics.DeliveryFailures.WithLabelValues(commandName(rp.section)).Inc()
+		slog.ErrorContext(ctx, "slack: interaction response error", "type", in.Type, "err", err)
+	}
+}
@@ -0,0 +1,211 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jesselucas/slackcmd/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func postInteraction(h http.Handler, payload string) *httptest.ResponseRecorder {
+	form := url.Values{"payload": {payload}}
+	r := httptest.NewRequest("POST", "/interact", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestInteractionBlockActions(t *testing.T) {
+	isolate(t)
+	posted := make(chan CommandPayload, 1)
+	var failing int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cp CommandPayload
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &cp)
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		posted <- cp
+	}))
+	defer ts.Close()
+
+	// response_urls are on Slack, the client sends them to ts
+	target, _ := url.Parse(ts.URL)
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.URL.Scheme, r.URL.Host = target.Scheme, target.Host
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+
+	HandleAction("test", "test_book", func(ctx context.Context, in *Interaction, a *Action) (*CommandPayload, error) {
+		room := DepsFrom(ctx).Settings.Get("room")
+		return &CommandPayload{Text: in.User.Id + " booked " + room + " at " + a.SelectedValue(), ReplaceOriginal: true}, nil
+	})
+	deps := func(section string) Deps {
+		return Deps{Settings: Settings{"room": section + "-room"}}
+	}
+
+	payload := `{"type":"block_actions","token":"secret","user":{"id":"U1"},"response_url":"https://hooks.slack.com/actions/T1/1/abc",` +
+		`"actions":[{"type":"static_select","action_id":"test_book","block_id":"b","selected_option":{"text":{"type":"plain_text","text":"9am"},"value":"0900"}}]}`
+
+	h := InteractionHandler("secret", nil, deps, nil, client)
+	w := postInteraction(h, payload)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Test errored. Status should be %v but is %v", http.StatusOK, w.Code)
+	}
+
+	select {
+	case cp := <-posted:
+		if cp.Text != "U1 booked test-room at 0900" || !cp.ReplaceOriginal {
+			t.Errorf("Test errored. Response should be %q but is %q", "U1 booked test-room at 0900", cp.Text)
+		}
+	case <-time.After(time.Second):
+		t.Error("Test errored. Response was never posted to response_url")
+	}
+
+	// failed deliveries are counted
+	atomic.StoreInt32(&failing, 1)
+	before := testutil.ToFloat64(metrics.DeliveryFailures.WithLabelValues("test"))
+	postInteraction(h, payload)
+	select {
+	case <-posted:
+	case <-time.After(time.Second):
+		t.Error("Test errored. Response was never posted to response_url")
+	}
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(metrics.DeliveryFailures.WithLabelValues("test"))-before != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := testutil.ToFloat64(metrics.DeliveryFailures.WithLabelValues("test")) - before; n != 1 {
+		t.Errorf("Test errored. Delivery failures should be %v but are %v", 1, n)
+	}
+
+	w = postInteraction(InteractionHandler("other", nil, nil, nil, client), payload)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Test errored. Status with wrong token should be %v but is %v", http.StatusForbidden, w.Code)
+	}
+
+	// the response_url comes from the request, only Slack's are posted to
+	w = postInteraction(h, strings.Replace(payload, "https://hooks.slack.com/actions/T1/1/abc", ts.URL, 1))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Test errored. Status with a response_url off Slack should be %v but is %v", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestInteractionViewSubmission(t *testing.T) {
+	isolate(t)
+	var title string
+	HandleCallback("test", "test_modal", func(ctx context.Context, in *Interaction) (*CommandPayload, error) {
+		title = in.View.State.Value("title", "input")
+		if title == "" {
+			return nil, ValidationErrors{"title": "A title is required"}
+		}
+		return nil, nil
+	})
+
+	payload := `{"type":"view_submission","token":"secret","view":{"id":"V1","type":"modal","callback_id":"test_modal",` +
+		`"blocks":[{"type":"input","block_id":"title"}],` +
+		`"state":{"values":{"title":{"input":{"type":"plain_text_input","value":"Fix login"}}}}}}`
+
+	w := postInteraction(InteractionHandler("secret", nil, nil, nil, nil), payload)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Test errored. Status should be %v but is %v", http.StatusOK, w.Code)
+	}
+	if title != "Fix login" {
+		t.Errorf("Test errored. Title should be %q but is %q", "Fix login", title)
+	}
+
+	// errors keep the modal open and are shown under the input
+	w = postInteraction(InteractionHandler("secret", nil, nil, nil, nil), strings.Replace(payload, "Fix login", "", 1))
+	expected := `{"response_action":"errors","errors":{"title":"A title is required"}}` + "\n"
+	if w.Body.String() != expected {
+		t.Errorf("Test errored. Response should be %v but is %v", expected, w.Body.String())
+	}
+
+	w = postInteraction(InteractionHandler("secret", nil, nil, nil, nil), `{"type":`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Test errored. Status for bad payload should be %v but is %v", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestInteractionViewSubmissionErrors(t *testing.T) {
+	isolate(t)
+	defer func(d time.Duration) { submissionTimeout = d }(submissionTimeout)
+	submissionTimeout = 50 * time.Millisecond
+
+	release := make(chan struct{})
+	HandleCallback("test", "test_fail", func(ctx context.Context, in *Interaction) (*CommandPayload, error) {
+		return nil, Errorf(BadInput, "There's no board named %q.", "Dev")
+	})
+	HandleCallback("test", "test_slow", func(ctx context.Context, in *Interaction) (*CommandPayload, error) {
+		<-release
+		return nil, Errorf(BadInput, "There's no list named %q.", "Doing")
+	})
+
+	f, ts, c := newFakeAPI(nil)
+	defer ts.Close()
+	clients := func(ctx context.Context, enterpriseID string, teamID string) (*Client, error) {
+		return c, nil
+	}
+	h := InteractionHandler("secret", clients, nil, nil, nil)
+
+	tests := []struct {
+		callbackID string
+		expected   string
+	}{
+		{"test_fail", "There's no board named \"Dev\"."},
+		// answered after Slack gave up on the modal
+		{"test_slow", "There's no list named \"Doing\"."},
+	}
+
+	for _, test := range tests {
+		payload := `{"type":"view_submission","token":"secret","user":{"id":"U1"},"team":{"id":"T1"},` +
+			`"view":{"id":"V1","type":"modal","callback_id":"` + test.callbackID + `"}}`
+
+		start := time.Now()
+		w := postInteraction(h, payload)
+		if w.Code != http.StatusOK || w.Body.Len() != 0 {
+			t.Errorf("Test errored. %v should close the modal but answered %v %q", test.callbackID, w.Code, w.Body.String())
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("Test errored. %v should be answered within the submission timeout but took %v", test.callbackID, d)
+		}
+		if test.callbackID == "test_slow" {
+			close(release)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		Wait(ctx)
+		cancel()
+
+		posted := f.requests["chat.postMessage"]
+		if len(posted) != 1 {
+			t.Fatalf("Test errored. %v should DM the user once but posted %v times", test.callbackID, len(posted))
+		}
+		var m struct {
+			Channel string `json:"channel"`
+			Text    string `json:"text"`
+		}
+		json.Unmarshal([]byte(posted[0]), &m)
+		if m.Channel != "U1" || !strings.HasPrefix(m.Text, test.expected) {
+			t.Errorf("Test errored. %v DM should be %q to U1 but is %q to %v", test.callbackID, test.expected, m.Text, m.Channel)
+		}
+		f.requests = make(map[string][]string)
+	}
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
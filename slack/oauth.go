@@ -0,0 +1,398 @@
+package slack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jesselucas/slackcmd/storage"
+)
+
+// DefaultAuthorizeURL is where users approve installing the app
+const DefaultAuthorizeURL = "https://slack.com/oauth/v2/authorize"
+
+// StateMaxAge is how long a user has to approve the install
+const StateMaxAge = 10 * time.Minute
+
+// stateCookie binds the OAuth state to the browser that started the install
+const stateCookie = "slackcmd_oauth_state"
+
+var (
+	ErrNoInstallation = errors.New("slack: no installation for workspace")
+	ErrInvalidState   = errors.New("slack: invalid oauth state")
+)
+
+// Installation is the bot token and identity of a workspace, or of an
+// Enterprise Grid org for org-wide installs, that installed the app
+type Installation struct {
+	TeamId              string
+	TeamName            string
+	EnterpriseId        string
+	EnterpriseName      string
+	IsEnterpriseInstall bool
+	AppId               string
+	BotUserId           string
+	BotToken            string
+	BotScopes           string
+	InstallerUserId     string
+	InstalledAt         time.Time
+}
+
+// Client returns a Web API client using the installation's bot token
+func (i *Installation) Client() *Client {
+	return NewClient(i.BotToken)
+}
+
+// InstallationStore saves installations by enterprise and team
+type InstallationStore interface {
+	SaveInstallation(ctx context.Context, i *Installation) error
+	// FindInstallation returns ErrNoInstallation if the workspace, or its
+	// org for org-wide installs, hasn't installed the app
+	FindInstallation(ctx context.Context, enterpriseID string, teamID string) (*Installation, error)
+	// DeleteInstallation removes the installation FindInstallation returns,
+	// the org's for org-wide installs
+	DeleteInstallation(ctx context.Context, enterpriseID string, teamID string) error
+}
+
+// installationKey identifies an installation. Org-wide installs are keyed by
+// enterprise only.
+func installationKey(enterpriseID string, teamID string) string {
+	return enterpriseID + "/" + teamID
+}
+
+func (i *Installation) key() string {
+	if i.IsEnterpriseInstall {
+		return installationKey(i.EnterpriseId, "")
+	}
+	return installationKey(i.EnterpriseId, i.TeamId)
+}
+
+// MemoryInstallationStore keeps installations in memory. They are lost
+// when the server restarts.
+type MemoryInstallationStore struct {
+	mu            sync.RWMutex
+	installations map[string]*Installation
+}
+
+func NewMemoryInstallationStore() *MemoryInstallationStore {
+	return &MemoryInstallationStore{installations: make(map[string]*Installation)}
+}
+
+func (s *MemoryInstallationStore) SaveInstallation(ctx context.Context, i *Installation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *i
+	s.installations[i.key()] = &saved
+	return nil
+}
+
+func (s *MemoryInstallationStore) FindInstallation(ctx context.Context, enterpriseID string, teamID string) (*Installation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.installations[installationKey(enterpriseID, teamID)]
+	if !ok && enterpriseID != "" {
+		i, ok = s.installations[installationKey(enterpriseID, "")]
+	}
+	if !ok {
+		return nil, ErrNoInstallation
+	}
+
+	found := *i
+	return &found, nil
+}
+
+func (s *MemoryInstallationStore) DeleteInstallation(ctx context.Context, enterpriseID string, teamID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := installationKey(enterpriseID, teamID)
+	if _, ok := s.installations[key]; !ok && enterpriseID != "" {
+		key = installationKey(enterpriseID, "")
+	}
+	delete(s.installations, key)
+	return nil
+}
+
+// StoredInstallations is an InstallationStore kept in a storage.Store so
+// installations survive restarts with the bolt or sqlite backends
+type StoredInstallations struct {
+	Store storage.Store
+}
+
+func NewStoredInstallations(s storage.Store) *StoredInstallations {
+	return &StoredInstallations{s}
+}
+
+func (s *StoredInstallations) SaveInstallation(ctx context.Context, i *Installation) error {
+	return storage.SetJSON(ctx, s.Store, "installation:"+i.key(), i, 0)
+}
+
+func (s *StoredInstallations) FindInstallation(ctx context.Context, enterpriseID string, teamID string) (*Installation, error) {
+	var i Installation
+	err := storage.GetJSON(ctx, s.Store, "installation:"+installationKey(enterpriseID, teamID), &i)
+	if err == storage.ErrNotFound && enterpriseID != "" {
+		err = storage.GetJSON(ctx, s.Store, "installation:"+installationKey(enterpriseID, ""), &i)
+	}
+	if err == storage.ErrNotFound {
+		return nil, ErrNoInstallation
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+func (s *StoredInstallations) DeleteInstallation(ctx context.Context, enterpriseID string, teamID string) error {
+	i, err := s.FindInstallation(ctx, enterpriseID, teamID)
+	if err == ErrNoInstallation {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.Store.Delete(ctx, "installation:"+i.key())
+}
+
+// ClientFunc returns the Web API client for a workspace. It returns a nil
+// Client when the workspace has no token.
+type ClientFunc func(ctx context.Context, enterpriseID string, teamID string) (*Client, error)
+
+type installationKeyCtx struct{}
+
+// WithInstallation returns a copy of ctx carrying the installation
+func WithInstallation(ctx context.Context, i *Installation) context.Context {
+	return context.WithValue(ctx, installationKeyCtx{}, i)
+}
+
+// InstallationFrom returns the installation in ctx or nil
+func InstallationFrom(ctx context.Context) *Installation {
+	i, _ := ctx.Value(installationKeyCtx{}).(*Installation)
+	return i
+}
+
+// OAuth runs Slack's OAuth v2 flow to install the app in a workspace
+// https://api.slack.com/authentication/oauth-v2
+type OAuth struct {
+	ClientId     string
+	ClientSecret string
+	Scopes       []string // bot scopes
+	UserScopes   []string
+	RedirectURL  string // optional, must match a redirect URL of the app
+	StateSecret  string // signs the state, defaults to ClientSecret
+	Store        InstallationStore
+
+	// Client calls oauth.v2.access and AuthorizeURL is the approval page.
+	// Both default to Slack's.
+	Client       *Client
+	AuthorizeURL string
+}
+
+// InstallHandler redirects the user to Slack to approve the install
+func (o *OAuth) InstallHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state, err := o.newState(time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     stateCookie,
+			Value:    state,
+			MaxAge:   int(StateMaxAge / time.Second),
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		q := url.Values{
+			"client_id": {o.ClientId},
+			"scope":     {strings.Join(o.Scopes, ",")},
+			"state":     {state},
+		}
+		if len(o.UserScopes) > 0 {
+			q.Set("user_scope", strings.Join(o.UserScopes, ","))
+		}
+		if o.RedirectURL != "" {
+			q.Set("redirect_uri", o.RedirectURL)
+		}
+
+		authorize := o.AuthorizeURL
+		if authorize == "" {
+			authorize = DefaultAuthorizeURL
+		}
+
+		http.Redirect(w, r, authorize+"?"+q.Encode(), http.StatusFound)
+	})
+}
+
+// CallbackHandler exchanges the code Slack redirects back with for a bot
+// token and saves the installation
+func (o *OAuth) CallbackHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			http.Error(w, "Install cancelled: "+e, http.StatusForbidden)
+			return
+		}
+
+		state := q.Get("state")
+		cookie, err := r.Cookie(stateCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+			http.Error(w, ErrInvalidState.Error(), http.StatusForbidden)
+			return
+		}
+		err = o.checkState(state, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: stateCookie, MaxAge: -1})
+
+		i, err := o.exchange(r.Context(), q.Get("code"))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "slack: oauth exchange error", "err", err)
+			http.Error(w, "Install failed", http.StatusBadGateway)
+			return
+		}
+
+		err = o.Store.SaveInstallation(r.Context(), i)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "slack: saving installation error", "team", i.TeamId, "err", err)
+			http.Error(w, "Install failed", http.StatusInternalServerError)
+			return
+		}
+
+		name := i.TeamName
+		if i.IsEnterpriseInstall {
+			name = i.EnterpriseName
+		}
+		fmt.Fprintf(w, "slackcmd is installed in %v. You can close this window.", name)
+	})
+}
+
+// exchange calls oauth.v2.access with the code
+func (o *OAuth) exchange(ctx context.Context, code string) (*Installation, error) {
+	if code == "" {
+		return nil, errors.New("missing code")
+	}
+
+	client := o.Client
+	if client == nil {
+		client = NewClient("")
+	}
+
+	params := url.Values{
+		"client_id":     {o.ClientId},
+		"client_secret": {o.ClientSecret},
+		"code":          {code},
+	}
+	if o.RedirectURL != "" {
+		params.Set("redirect_uri", o.RedirectURL)
+	}
+
+	var r struct {
+		AccessToken string `json:"access_token"`
+		Scope       string `json:"scope"`
+		BotUserId   string `json:"bot_user_id"`
+		AppId       string `json:"app_id"`
+		Team        *struct {
+			Id   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"team"`
+		Enterprise *struct {
+			Id   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"enterprise"`
+		AuthedUser struct {
+			Id string `json:"id"`
+		} `json:"authed_user"`
+		IsEnterpriseInstall bool `json:"is_enterprise_install"`
+	}
+	err := client.callForm(ctx, "oauth.v2.access", params, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &Installation{
+		IsEnterpriseInstall: r.IsEnterpriseInstall,
+		AppId:               r.AppId,
+		BotUserId:           r.BotUserId,
+		BotToken:            r.AccessToken,
+		BotScopes:           r.Scope,
+		InstallerUserId:     r.AuthedUser.Id,
+		InstalledAt:         time.Now(),
+	}
+	if r.Team != nil {
+		i.TeamId, i.TeamName = r.Team.Id, r.Team.Name
+	}
+	if r.Enterprise != nil {
+		i.EnterpriseId, i.EnterpriseName = r.Enterprise.Id, r.Enterprise.Name
+	}
+
+	return i, nil
+}
+
+// newState returns "nonce.timestamp.signature"
+func (o *OAuth) newState(now time.Time) (string, error) {
+	nonce := make([]byte, 16)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	payload := hex.EncodeToString(nonce) + "." + strconv.FormatInt(now.Unix(), 10)
+	return payload + "." + o.signState(payload), nil
+}
+
+// checkState verifies the signature and age of a state from newState
+func (o *OAuth) checkState(state string, now time.Time) error {
+	i := strings.LastIndex(state, ".")
+	if i < 0 {
+		return ErrInvalidState
+	}
+	payload, signature := state[:i], state[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(o.signState(payload))) {
+		return ErrInvalidState
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 2 {
+		return ErrInvalidState
+	}
+	ts, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return ErrInvalidState
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age < 0 || age > StateMaxAge {
+		return ErrInvalidState
+	}
+
+	return nil
+}
+
+func (o *OAuth) signState(payload string) string {
+	secret := o.StateSecret
+	if secret == "" {
+		secret = o.ClientSecret
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
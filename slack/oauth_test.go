@@ -0,0 +1,142 @@
+package slack
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jesselucas/slackcmd/storage"
+)
+
+func TestOAuthState(t *testing.T) {
+	o := &OAuth{ClientSecret: "secret"}
+	now := time.Now()
+
+	state, err := o.newState(now)
+	if err != nil {
+		t.Fatal("Test errored. newState returned", err)
+	}
+
+	tests := []struct {
+		state string
+		at    time.Time
+		valid bool
+	}{
+		{state, now, true},
+		{state, now.Add(StateMaxAge - time.Second), true},
+		{state, now.Add(StateMaxAge + time.Second), false},
+		{state + "0", now, false},
+		{"0" + state, now, false},
+		{"", now, false},
+	}
+
+	for _, test := range tests {
+		err := o.checkState(test.state, test.at)
+		if (err == nil) != test.valid {
+			t.Errorf("Test errored. checkState(%q) should be valid %v but returned %v", test.state, test.valid, err)
+		}
+	}
+
+	other := &OAuth{ClientSecret: "other"}
+	if other.checkState(state, now) == nil {
+		t.Error("Test errored. State signed with another secret should be invalid")
+	}
+}
+
+func TestInstallationStores(t *testing.T) {
+	for _, s := range []InstallationStore{NewMemoryInstallationStore(), NewStoredInstallations(storage.NewMemory())} {
+		testInstallationStore(t, s)
+	}
+}
+
+func testInstallationStore(t *testing.T, s InstallationStore) {
+	ctx := context.Background()
+	s.SaveInstallation(ctx, &Installation{TeamId: "T1", BotToken: "xoxb-1"})
+	s.SaveInstallation(ctx, &Installation{EnterpriseId: "E1", IsEnterpriseInstall: true, BotToken: "xoxb-org"})
+
+	tests := []struct {
+		enterpriseID string
+		teamID       string
+		token        string
+	}{
+		{"", "T1", "xoxb-1"},
+		{"E1", "T2", "xoxb-org"}, // org-wide install covers every team
+		{"", "T2", ""},
+	}
+
+	for _, test := range tests {
+		i, err := s.FindInstallation(ctx, test.enterpriseID, test.teamID)
+		if test.token == "" {
+			if err != ErrNoInstallation {
+				t.Errorf("Test errored. %v/%v should not be installed but returned %v", test.enterpriseID, test.teamID, err)
+			}
+			continue
+		}
+		if err != nil || i.BotToken != test.token {
+			t.Errorf("Test errored. %v/%v token should be %v but is %v (%v)", test.enterpriseID, test.teamID, test.token, i, err)
+		}
+	}
+
+	s.DeleteInstallation(ctx, "", "T1")
+	if _, err := s.FindInstallation(ctx, "", "T1"); err != ErrNoInstallation {
+		t.Errorf("Test errored. Deleted installation should be gone but returned %v", err)
+	}
+
+	// uninstalling from a team of the org removes the org-wide install
+	s.DeleteInstallation(ctx, "E1", "T2")
+	if _, err := s.FindInstallation(ctx, "E1", "T3"); err != ErrNoInstallation {
+		t.Errorf("Test errored. Deleted org-wide installation should be gone but returned %v", err)
+	}
+}
+
+func TestOAuthFlow(t *testing.T) {
+	_, api, client := newFakeAPI(map[string][]string{
+		"oauth.v2.access": {`{"ok":true,"access_token":"xoxb-team","scope":"commands,chat:write","bot_user_id":"UB","app_id":"A1",` +
+			`"team":{"id":"T9","name":"Forest Giant"},"enterprise":null,"authed_user":{"id":"U1"},"is_enterprise_install":false}`},
+	})
+	defer api.Close()
+	client.Token = ""
+
+	store := NewMemoryInstallationStore()
+	o := &OAuth{ClientId: "123.456", ClientSecret: "secret", Scopes: []string{"commands", "chat:write"}, Store: store, Client: client}
+
+	// install redirects to Slack with a state bound to a cookie
+	w := httptest.NewRecorder()
+	o.InstallHandler().ServeHTTP(w, httptest.NewRequest("GET", "/install", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("Test errored. Status should be %v but is %v", http.StatusFound, w.Code)
+	}
+	location, _ := url.Parse(w.Header().Get("Location"))
+	state := location.Query().Get("state")
+	if location.Query().Get("scope") != "commands,chat:write" || state == "" {
+		t.Errorf("Test errored. Redirect should have scopes and state but is %v", location)
+	}
+	cookie := w.Result().Cookies()[0]
+	if !cookie.Secure || !cookie.HttpOnly {
+		t.Errorf("Test errored. State cookie should be Secure and HttpOnly but is %v", cookie)
+	}
+
+	// the callback fails without the cookie
+	r := httptest.NewRequest("GET", "/oauth/callback?code=abc&state="+url.QueryEscape(state), nil)
+	w = httptest.NewRecorder()
+	o.CallbackHandler().ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Test errored. Status without cookie should be %v but is %v", http.StatusForbidden, w.Code)
+	}
+
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	o.CallbackHandler().ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Forest Giant") {
+		t.Fatalf("Test errored. Install should succeed but returned %v %v", w.Code, w.Body.String())
+	}
+
+	i, err := store.FindInstallation(context.Background(), "", "T9")
+	if err != nil || i.BotToken != "xoxb-team" || i.InstallerUserId != "U1" {
+		t.Errorf("Test errored. Installation should be saved but is %+v (%v)", i, err)
+	}
+}
@@ -0,0 +1,46 @@
+package slack
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// PanicError is returned by Safely when the function it runs panics. It's
+// an Internal error, so users are told the command failed without the
+// details.
+type PanicError struct {
+	Value interface{} // passed to panic
+	Stack []byte      // of the goroutine that panicked
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("slack: panic: %v", e.Value)
+}
+
+// Safely runs fn and turns a panic into a *PanicError so one broken command
+// can't take the request or the server down with it
+func Safely(fn func() (*CommandPayload, error)) (cp *CommandPayload, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			cp, err = nil, &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// stackAttr is the stack of a *PanicError to log with it, an empty attr
+// slog drops for other errors
+func stackAttr(err error) slog.Attr {
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		return slog.Attr{}
+	}
+	return slog.String("stack", string(pe.Stack))
+}
+
+// logPanic logs a recovered panic with its stack
+func logPanic(msg string, v interface{}) {
+	slog.Error(msg, "err", &PanicError{Value: v}, "stack", string(debug.Stack()))
+}
@@ -0,0 +1,57 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSafely(t *testing.T) {
+	var nilMap map[string]int
+	tests := []struct {
+		name  string
+		fn    func() (*CommandPayload, error)
+		text  string
+		err   string
+		panic bool
+	}{
+		{"payload", func() (*CommandPayload, error) { return &CommandPayload{Text: "ok"}, nil }, "ok", "", false},
+		{"error", func() (*CommandPayload, error) { return nil, errors.New("boom") }, "", "boom", false},
+		{"panic", func() (*CommandPayload, error) { panic("trello key missing") }, "", "slack: panic: trello key missing", true},
+		{"runtime panic", func() (*CommandPayload, error) { nilMap["x"] = 1; return nil, nil }, "", "slack: panic: assignment to entry in nil map", true},
+	}
+
+	for _, test := range tests {
+		cp, err := Safely(test.fn)
+		if test.text != "" && (cp == nil || cp.Text != test.text) {
+			t.Errorf("Test errored. %v payload should be %q but is %v", test.name, test.text, cp)
+		}
+		if test.err == "" && err != nil || test.err != "" && (err == nil || err.Error() != test.err) {
+			t.Errorf("Test errored. %v error should be %q but is %v", test.name, test.err, err)
+		}
+
+		var pe *PanicError
+		if errors.As(err, &pe) != test.panic {
+			t.Errorf("Test errored. %v error should be a panic: %v but is %T", test.name, test.panic, err)
+			continue
+		}
+		if test.panic && !strings.Contains(string(pe.Stack), "TestSafely") {
+			t.Errorf("Test errored. %v stack should include the panicking function but is %s", test.name, pe.Stack)
+		}
+		if test.panic && KindOf(err) != Internal {
+			t.Errorf("Test errored. %v kind should be %v but is %v", test.name, Internal, KindOf(err))
+		}
+	}
+}
+
+func TestGoRecovers(t *testing.T) {
+	Go(func() {
+		panic("background")
+	})
+
+	// the panic would have crashed the test binary
+	if err := Wait(context.Background()); err != nil {
+		t.Error("Test errored. Wait returned", err)
+	}
+}
@@ -0,0 +1,222 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jesselucas/slackcmd/storage"
+)
+
+// DefaultTimeout limits how long a command may run when CommandInfo.Timeout
+// is not set
+const DefaultTimeout = 30 * time.Second
+
+// CommandInfo describes a slash command that can be served by slackcmd.
+// Command packages register one from an init function.
+type CommandInfo struct {
+	Names       []string          // slash names including the leading "/", Ex. "/fg"
+	Description string            // short description used in help output
+	Usage       string            // optional help line, defaults to "<name> help: <Description>"
+	TokenEnv    string            // env var holding the legacy verification token, Ex. "SLACK_KEY_QOTD"
+	LegacyToken bool              // accept unsigned requests with the legacy token even when a signing secret is set
+	Section     string            // config section of the command, defaults to the name without "/"
+	Settings    []Setting         // settings read from the config section
+	Timeout     time.Duration     // deadline for a single request, defaults to DefaultTimeout
+	Flags       func(fs *FlagSet) // declares flags of the command besides the global ones
+	New         func(deps Deps) Command
+
+	// Check reports whether the services the command calls are reachable
+	// with its settings. It is optional and run by the readiness endpoint.
+	Check func(ctx context.Context, deps Deps) error
+}
+
+// Deps are the shared services a command is constructed with
+type Deps struct {
+	Store    storage.Store // namespaced to the command so keys can't collide
+	Settings Settings      // the command's resolved Settings
+
+	// HTTPClient calls upstream services and records their latency.
+	// Commands fall back to http.DefaultClient when it is nil.
+	HTTPClient *http.Client
+
+	// Logger writes structured logs labelled with the command. Log with
+	// the request's ctx to include its correlation ID. Commands fall back
+	// to slog.Default() when it is nil.
+	Logger *slog.Logger
+}
+
+// DepsFunc returns the Deps of the command with a config section. It is
+// called for every request so settings reloaded from the config apply.
+type DepsFunc func(section string) Deps
+
+// Trigger is the workspace, channel and user an interaction or event came
+// from
+type Trigger struct {
+	TeamId    string
+	ChannelId string
+	UserId    string
+}
+
+// GuardFunc runs a handler the command with a config section registered for
+// interactions or events, Ex. to skip it while the command is turned off and
+// count its panics as the command's. run recovers from panics.
+type GuardFunc func(ctx context.Context, section string, t Trigger, run func(ctx context.Context) (*CommandPayload, error)) (*CommandPayload, error)
+
+// guarded returns run recovering from panics and guarded by g
+func (g GuardFunc) guarded(section string, t Trigger, run func(ctx context.Context) (*CommandPayload, error)) func(ctx context.Context) (*CommandPayload, error) {
+	safe := func(ctx context.Context) (*CommandPayload, error) {
+		return Safely(func() (*CommandPayload, error) { return run(ctx) })
+	}
+	if g == nil || section == "" {
+		return safe
+	}
+	return func(ctx context.Context) (*CommandPayload, error) {
+		return g(ctx, section, t, safe)
+	}
+}
+
+type depsKey struct{}
+
+// WithDeps returns a copy of ctx carrying deps
+func WithDeps(ctx context.Context, deps Deps) context.Context {
+	return context.WithValue(ctx, depsKey{}, deps)
+}
+
+// DepsFrom returns the Deps in ctx. Interaction and event handlers get the
+// Deps of the command that registered them.
+func DepsFrom(ctx context.Context) Deps {
+	deps, _ := ctx.Value(depsKey{}).(Deps)
+	return deps
+}
+
+// RequestTimeout returns the deadline to impose on a single request
+func (ci *CommandInfo) RequestTimeout() time.Duration {
+	if ci.Timeout > 0 {
+		return ci.Timeout
+	}
+	return DefaultTimeout
+}
+
+// ConfigSection returns the name of the command's config section
+func (ci *CommandInfo) ConfigSection() string {
+	if ci.Section != "" {
+		return ci.Section
+	}
+	return strings.TrimPrefix(ci.Name(), "/")
+}
+
+// AllSettings returns the command's Settings and its legacy verification
+// token, which is read from the "verification_token" key
+func (ci *CommandInfo) AllSettings() []Setting {
+	settings := append([]Setting{}, ci.Settings...)
+	if ci.TokenEnv != "" {
+		settings = append(settings, Setting{Key: "verification_token", Env: ci.TokenEnv, Usage: "legacy verification token"})
+	}
+	return settings
+}
+
+// Name returns the primary slash name of the command
+func (ci *CommandInfo) Name() string {
+	if len(ci.Names) == 0 {
+		return ""
+	}
+	return ci.Names[0]
+}
+
+// UsageFor returns the help line shown for the slash name used to invoke
+// the command
+func (ci *CommandInfo) UsageFor(name string) string {
+	if ci.Usage != "" {
+		return ci.Usage
+	}
+	return fmt.Sprintf("%v help: %v", name, ci.Description)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*CommandInfo)
+)
+
+// Register makes a command available by each of its slash names.
+// It panics if a name is empty, missing the "/" prefix, or already
+// registered so conflicts are caught when the server starts.
+func Register(ci CommandInfo) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if len(ci.Names) == 0 {
+		panic("slack: Register command without a name")
+	}
+	if ci.New == nil {
+		panic("slack: Register command " + ci.Names[0] + " without a constructor")
+	}
+
+	for _, name := range ci.Names {
+		if len(name) < 2 || name[0] != '/' {
+			panic("slack: Register invalid command name " + fmt.Sprintf("%q", name))
+		}
+		if _, dup := registry[name]; dup {
+			panic("slack: Register called twice for command " + name)
+		}
+	}
+
+	info := &ci
+	for _, name := range ci.Names {
+		registry[name] = info
+	}
+}
+
+// Lookup returns the command registered for a slash name
+func Lookup(name string) (*CommandInfo, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	ci, ok := registry[name]
+	return ci, ok
+}
+
+// Commands returns every registered command sorted by primary name
+func Commands() []*CommandInfo {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	seen := make(map[*CommandInfo]bool)
+	var infos []*CommandInfo
+	for _, ci := range registry {
+		if !seen[ci] {
+			seen[ci] = true
+			infos = append(infos, ci)
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+
+	return infos
+}
+
+// LookupSection returns the command with a config section
+func LookupSection(section string) (*CommandInfo, bool) {
+	for _, ci := range Commands() {
+		if ci.ConfigSection() == section {
+			return ci, true
+		}
+	}
+	return nil, false
+}
+
+// commandName returns the name of the command with a config section, or
+// the section when no command has it
+func commandName(section string) string {
+	if ci, ok := LookupSection(section); ok {
+		return ci.Name()
+	}
+	return section
+}
@@ -0,0 +1,123 @@
+package slack
+
+import (
+	"testing"
+)
+
+type testCommand struct{}
+
+func (cmd *testCommand) Request(sc *SlashCommand) (*CommandPayload, error) {
+	return &CommandPayload{Text: sc.Text, SlashResponse: true}, nil
+}
+
+func newTestCommand(deps Deps) Command {
+	return &testCommand{}
+}
+
+// isolate restores the command, interaction and event handlers registered
+// when the test ends, so tests can register theirs again with -count
+func isolate(t *testing.T) {
+	registryMu.Lock()
+	commands := make(map[string]*CommandInfo)
+	for name, ci := range registry {
+		commands[name] = ci
+	}
+	registryMu.Unlock()
+
+	interactionMu.Lock()
+	savedActions := make(map[string]actionHandler)
+	for id, h := range actions {
+		savedActions[id] = h
+	}
+	savedCallbacks := make(map[string]callbackHandler)
+	for id, h := range callbacks {
+		savedCallbacks[id] = h
+	}
+	interactionMu.Unlock()
+
+	eventMu.Lock()
+	events := make(map[string][]eventHandler)
+	for name, hs := range eventHandlers {
+		events[name] = hs
+	}
+	eventMu.Unlock()
+
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = commands
+		registryMu.Unlock()
+
+		interactionMu.Lock()
+		actions, callbacks = savedActions, savedCallbacks
+		interactionMu.Unlock()
+
+		eventMu.Lock()
+		eventHandlers = events
+		eventMu.Unlock()
+	})
+}
+
+func TestRegister(t *testing.T) {
+	isolate(t)
+	Register(CommandInfo{
+		Names:       []string{"/registrytest", "/rt"},
+		Description: "Registry test",
+		New:         newTestCommand,
+	})
+
+	for _, name := range []string{"/registrytest", "/rt"} {
+		ci, ok := Lookup(name)
+		if !ok {
+			t.Fatalf("Test errored. %v should be registered", name)
+		}
+		if ci.Name() != "/registrytest" {
+			t.Errorf("Test errored. Name should be /registrytest but is %v", ci.Name())
+		}
+	}
+
+	ci, _ := Lookup("/rt")
+	if usage := ci.UsageFor("/rt"); usage != "/rt help: Registry test" {
+		t.Errorf("Test errored. Usage should be '/rt help: Registry test' but is %v", usage)
+	}
+
+	var found int
+	for _, ci := range Commands() {
+		if ci.Name() == "/registrytest" {
+			found++
+		}
+	}
+	if found != 1 {
+		t.Errorf("Test errored. /registrytest should be listed once but was listed %v times", found)
+	}
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	isolate(t)
+	Register(CommandInfo{Names: []string{"/duplicatetest"}, New: newTestCommand})
+
+	tests := []struct {
+		name string
+		ci   CommandInfo
+	}{
+		{"duplicate", CommandInfo{Names: []string{"/other", "/duplicatetest"}, New: newTestCommand}},
+		{"no names", CommandInfo{New: newTestCommand}},
+		{"no prefix", CommandInfo{Names: []string{"noprefix"}, New: newTestCommand}},
+		{"no constructor", CommandInfo{Names: []string{"/noconstructor"}}},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Test errored. Register should panic for %v", test.name)
+				}
+			}()
+			Register(test.ci)
+		}()
+	}
+
+	// a rejected registration must not leave partial entries behind
+	if _, ok := Lookup("/other"); ok {
+		t.Error("Test errored. /other should not be registered")
+	}
+}
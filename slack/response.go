@@ -0,0 +1,114 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Slack accepts up to MaxResponses posts to a response_url within
+// ResponseWindow of the slash command being invoked
+// https://api.slack.com/interactivity/handling#message_responses
+const (
+	MaxResponses   = 5
+	ResponseWindow = 30 * time.Minute
+)
+
+// Values for CommandPayload.ResponseType
+const (
+	ResponseEphemeral = "ephemeral"
+	ResponseInChannel = "in_channel"
+)
+
+var (
+	ErrResponseLimit   = errors.New("slack: response_url has been used the maximum number of times")
+	ErrResponseExpired = errors.New("slack: response_url has expired")
+)
+
+// Responder posts deferred responses to the response_url Slack sends
+// with a slash command. It is safe for concurrent use.
+type Responder struct {
+	URL    string
+	Client *http.Client // defaults to http.DefaultClient
+
+	mu        sync.Mutex
+	expires   time.Time
+	remaining int
+}
+
+// NewResponder returns a Responder for url starting the 30 minute window now
+func NewResponder(url string) *Responder {
+	return &Responder{
+		URL:       url,
+		expires:   time.Now().Add(ResponseWindow),
+		remaining: MaxResponses,
+	}
+}
+
+// Remaining returns how many more responses can be sent
+func (r *Responder) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Now().After(r.expires) {
+		return 0
+	}
+	return r.remaining
+}
+
+// Send posts the payload as JSON to the response_url
+func (r *Responder) Send(cp *CommandPayload) error {
+	return r.SendContext(context.Background(), cp)
+}
+
+// SendContext is Send with a context that cancels the post and carries the
+// request's correlation ID
+func (r *Responder) SendContext(ctx context.Context, cp *CommandPayload) error {
+	r.mu.Lock()
+	if time.Now().After(r.expires) {
+		r.mu.Unlock()
+		return ErrResponseExpired
+	}
+	if r.remaining <= 0 {
+		r.mu.Unlock()
+		return ErrResponseLimit
+	}
+	r.remaining--
+	r.mu.Unlock()
+
+	cpJSON, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", r.URL, bytes.NewReader(cpJSON))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack: response_url returned %v", res.Status)
+	}
+
+	return nil
+}
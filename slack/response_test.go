@@ -0,0 +1,79 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jesselucas/slackcmd/logging"
+)
+
+func TestResponder(t *testing.T) {
+	var received []CommandPayload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cp CommandPayload
+		if err := json.NewDecoder(r.Body).Decode(&cp); err != nil {
+			t.Error("Test errored. Unable to decode payload:", err)
+		}
+		received = append(received, cp)
+	}))
+	defer ts.Close()
+
+	rs := NewResponder(ts.URL)
+	for i := 0; i < MaxResponses; i++ {
+		err := rs.Send(&CommandPayload{Text: "follow up", ResponseType: ResponseInChannel})
+		if err != nil {
+			t.Errorf("Test errored. Send %v should succeed but returned %v", i, err)
+		}
+	}
+
+	if err := rs.Send(&CommandPayload{Text: "one too many"}); err != ErrResponseLimit {
+		t.Errorf("Test errored. Error should be %v but is %v", ErrResponseLimit, err)
+	}
+
+	if len(received) != MaxResponses {
+		t.Errorf("Test errored. %v responses should be received but got %v", MaxResponses, len(received))
+	}
+	if received[0].ResponseType != ResponseInChannel {
+		t.Errorf("Test errored. ResponseType should be %v but is %v", ResponseInChannel, received[0].ResponseType)
+	}
+
+	// responses after the window closes are rejected
+	rs = NewResponder(ts.URL)
+	rs.expires = time.Now().Add(-time.Second)
+	if err := rs.Send(&CommandPayload{Text: "too late"}); err != ErrResponseExpired {
+		t.Errorf("Test errored. Error should be %v but is %v", ErrResponseExpired, err)
+	}
+	if rs.Remaining() != 0 {
+		t.Errorf("Test errored. Remaining should be 0 but is %v", rs.Remaining())
+	}
+}
+
+func TestResponderSendContext(t *testing.T) {
+	ids := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids <- r.Header.Get(logging.Header)
+	}))
+	defer ts.Close()
+
+	// the correlating transport reads the request ID from the context
+	rs := NewResponder(ts.URL)
+	rs.Client = &http.Client{Transport: logging.Transport(nil)}
+	ctx := logging.WithID(context.Background(), "req-1")
+	if err := rs.SendContext(ctx, &CommandPayload{Text: "hi"}); err != nil {
+		t.Fatal("Test errored. SendContext returned", err)
+	}
+	if id := <-ids; id != "req-1" {
+		t.Errorf("Test errored. Request ID should be %q but is %q", "req-1", id)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := rs.SendContext(ctx, &CommandPayload{Text: "hi"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Test errored. A cancelled send should return %v but returned %v", context.Canceled, err)
+	}
+}
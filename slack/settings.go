@@ -0,0 +1,69 @@
+package slack
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrNotConfigured is returned by commands and handlers missing a Required
+// setting
+var ErrNotConfigured = errors.New("slack: command is not configured")
+
+// Setting is a configuration value a command declares in
+// CommandInfo.Settings. Its value comes from the command's section of the
+// config file, Ex. commands.qotd.url, overridden by the env var.
+type Setting struct {
+	Key     string // key in the command's config section, Ex. "url"
+	Env     string // env var overriding the config file, Ex. "QOTD_URL"
+	Default string
+	Usage   string
+
+	// Required settings without a value disable the command
+	Required bool
+}
+
+// Settings are a command's resolved setting values by key
+type Settings map[string]string
+
+// Get returns the value of a setting or "" if it isn't set
+func (s Settings) Get(key string) string {
+	return s[key]
+}
+
+// ResolveSettings returns the value of each setting from the env, the
+// config section or its default, and the settings left without a value
+func ResolveSettings(defs []Setting, section map[string]string) (Settings, []Setting) {
+	settings := make(Settings)
+	var missing []Setting
+
+	for _, def := range defs {
+		value := section[def.Key]
+		if def.Env != "" {
+			if env, ok := os.LookupEnv(def.Env); ok && env != "" {
+				value = env
+			}
+		}
+		if value == "" {
+			value = def.Default
+		}
+
+		if value == "" {
+			missing = append(missing, def)
+			continue
+		}
+		settings[def.Key] = value
+	}
+
+	return settings, missing
+}
+
+// Required returns the required settings of missing
+func Required(missing []Setting) []Setting {
+	var required []Setting
+	for _, m := range missing {
+		if m.Required {
+			required = append(required, m)
+		}
+	}
+	return required
+}
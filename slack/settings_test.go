@@ -0,0 +1,60 @@
+package slack
+
+import (
+	"os"
+	"testing"
+)
+
+func TestResolveSettings(t *testing.T) {
+	os.Setenv("SLACKCMD_TEST_ORG", "envorg")
+	defer os.Unsetenv("SLACKCMD_TEST_ORG")
+
+	defs := []Setting{
+		{Key: "key"},
+		{Key: "org", Env: "SLACKCMD_TEST_ORG", Default: "forestgiant"},
+		{Key: "board", Default: "Dev"},
+		{Key: "token", Env: "SLACKCMD_TEST_UNSET", Required: true},
+		{Key: "secret"},
+	}
+	section := map[string]string{"key": "abc", "org": "fileorg"}
+
+	settings, missing := ResolveSettings(defs, section)
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"key", "abc"},
+		{"org", "envorg"}, // env overrides the file
+		{"board", "Dev"},
+		{"token", ""},
+	}
+
+	for _, test := range tests {
+		if settings.Get(test.key) != test.expected {
+			t.Errorf("Test errored. %v should be %q but is %q", test.key, test.expected, settings.Get(test.key))
+		}
+	}
+
+	if len(missing) != 2 || missing[0].Key != "token" || missing[1].Key != "secret" {
+		t.Errorf("Test errored. Missing should be [token secret] but is %v", missing)
+	}
+	if required := Required(missing); len(required) != 1 || required[0].Key != "token" {
+		t.Errorf("Test errored. Required should be [token] but is %v", required)
+	}
+}
+
+func TestCommandInfoSettings(t *testing.T) {
+	ci := &CommandInfo{Names: []string{"/fg"}, Section: "trello", TokenEnv: "SLACK_KEY_FG"}
+	if ci.ConfigSection() != "trello" {
+		t.Errorf("Test errored. Section should be trello but is %v", ci.ConfigSection())
+	}
+	if ci := (&CommandInfo{Names: []string{"/qotd"}}); ci.ConfigSection() != "qotd" {
+		t.Errorf("Test errored. Section should be qotd but is %v", ci.ConfigSection())
+	}
+
+	settings := Settings{"verification_token": "abc"}
+	if !ci.VerifyToken(settings, "abc") || ci.VerifyToken(settings, "xyz") || ci.VerifyToken(Settings{}, "") {
+		t.Error("Test errored. VerifyToken should only accept the verification_token setting")
+	}
+}
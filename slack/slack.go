@@ -1,6 +1,7 @@
 package slack
 
 import (
+	"context"
 	"strings"
 )
 
@@ -9,6 +10,39 @@ type Command interface {
 	Request(sc *SlashCommand) (*CommandPayload, error)
 }
 
+// ContextCommand is implemented by commands that honor cancellation and
+// deadlines. ctx should be passed on to every outbound request.
+type ContextCommand interface {
+	RequestContext(ctx context.Context, sc *SlashCommand) (*CommandPayload, error)
+}
+
+// FlagDefiner is implemented by commands whose flags depend on the command
+// text, such as a Subcommand tree. DefineFlags is called before the flags
+// are separated from the text.
+type FlagDefiner interface {
+	DefineFlags(fs *FlagSet, text string)
+}
+
+// WithContext returns cmd as a ContextCommand. Commands that only
+// implement Request are wrapped and ignore ctx.
+func WithContext(cmd Command) ContextCommand {
+	if cc, ok := cmd.(ContextCommand); ok {
+		return cc
+	}
+	return requestAdapter{cmd}
+}
+
+type requestAdapter struct {
+	cmd Command
+}
+
+func (a requestAdapter) RequestContext(ctx context.Context, sc *SlashCommand) (*CommandPayload, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.cmd.Request(sc)
+}
+
 type Field struct {
 	Title string `json:"title"`
 	Value string `json:"value"`
@@ -27,67 +61,96 @@ type Attachment struct {
 
 // CommandPayload https://api.slack.com/docs/formatting
 type CommandPayload struct {
-	Channel       string       `json:"channel"`
-	Username      string       `json:"username"`
-	Emoji         string       `json:"icon_emoji"`
-	EmojiURL      string       `json:"icon_url"`
-	Text          string       `json:"text"`
-	Attachments   []Attachment `json:"attachments"`
-	UnfurlMedia   bool         `json:"unfurl_media"`
-	UnfurlLinks   bool         `json:"unfurl_links"`
-	Parse         string       `json:"parse"`
-	SlashResponse bool
-	SendPayload   bool
+	Channel      string       `json:"channel"`
+	Username     string       `json:"username"`
+	Emoji        string       `json:"icon_emoji"`
+	EmojiURL     string       `json:"icon_url"`
+	Text         string       `json:"text"`
+	Attachments  []Attachment `json:"attachments"`
+	Blocks       []Block      `json:"blocks,omitempty"`
+	UnfurlMedia  bool         `json:"unfurl_media"`
+	UnfurlLinks  bool         `json:"unfurl_links"`
+	Parse        string       `json:"parse"`
+	ResponseType string       `json:"response_type,omitempty"`
+	// ReplaceOriginal and DeleteOriginal apply to response_url posts for
+	// interactions with a message
+	ReplaceOriginal bool `json:"replace_original,omitempty"`
+	DeleteOriginal  bool `json:"delete_original,omitempty"`
+	// Modal is opened with the command's trigger_id instead of sending a
+	// message. Returned from a view_submission it replaces the open modal.
+	Modal *View `json:"-"`
+	// Unfurls previews the links of a link_shared event instead of sending
+	// a message. They're keyed by URL.
+	Unfurls       map[string]Attachment `json:"-"`
+	SlashResponse bool                  `json:"-"`
+	SendPayload   bool                  `json:"-"`
 }
 
 // struct to hold params sent from slacks slash command
+// https://api.slack.com/interactivity/slash-commands#app_command_handling
 type SlashCommand struct {
-	Token       string
-	TeamId      string
-	TeamDomain  string
-	ChannelId   string
-	ChannelName string
-	UserId      string
-	UserName    string
-	Command     string
-	Text        string
-	Hook        string
+	Token               string
+	TeamId              string
+	TeamDomain          string
+	EnterpriseId        string
+	EnterpriseName      string
+	ChannelId           string
+	ChannelName         string
+	UserId              string
+	UserName            string
+	Command             string
+	Text                string
+	APIAppId            string
+	IsEnterpriseInstall bool
+	ResponseURL         string
+	TriggerId           string
+	Hook                string // legacy incoming webhook passed by older clients
+
+	// Args and Flags hold the text once flags are separated and parsed so
+	// commands can read their values
+	Args  []string
+	Flags *FlagSet
+
+	// Responder delivers deferred responses when the command runs in the
+	// background. It is nil when Slack did not send a response_url.
+	Responder *Responder
+
+	// Installation is the workspace's OAuth installation and Client calls
+	// the Web API with its bot token. Without OAuth Client uses
+	// SLACK_BOT_TOKEN. Either may be nil.
+	Installation *Installation
+	Client       *Client
 }
 
 // Takes Slack slash command text and parses out any flags
 // Ex. "golang links -c" returns "golang links"
 func SeparateFlags(t string) (c string, f []string) {
-	var splitFlags = strings.Fields(t)
-	var parsedFlags []string
-	var trimmedFlag string
-	var parsedCommands []string
-
-	// first seperate the string into an slice of strings
-	for _, value := range splitFlags {
-		// Test for flags and remove prefix.
-		// Check -- first since - will always find --
-		if strings.HasPrefix(value, "-") || strings.HasPrefix(value, "—") {
-			if strings.HasPrefix(value, "——") {
-				trimmedFlag = strings.TrimPrefix(value, "——")
-				parsedFlags = append(parsedFlags, trimmedFlag)
-			} else if strings.HasPrefix(value, "--") {
-				trimmedFlag = strings.TrimPrefix(value, "--")
-				parsedFlags = append(parsedFlags, trimmedFlag)
-			} else if strings.HasPrefix(value, "—") {
-				trimmedFlag = strings.TrimPrefix(value, "—")
-				parsedFlags = append(parsedFlags, trimmedFlag)
-			} else {
-				trimmedFlag = strings.TrimPrefix(value, "-")
-				parsedFlags = append(parsedFlags, trimmedFlag)
-			}
-
-		} else {
-			parsedCommands = append(parsedCommands, value)
+	tokens, err := Tokenize(t)
+	if err != nil {
+		// text that can't be tokenized is split on spaces
+		tokens = nil
+		for _, value := range strings.Fields(t) {
+			tokens = append(tokens, Token{Value: value})
 		}
 	}
 
+	args, parsedFlags, _ := separate(nil, tokens)
+
 	// Return string without flags and flags
-	return strings.Join(parsedCommands, " "), parsedFlags
+	return strings.Join(args, " "), parsedFlags
+}
+
+// trimFlag tests for a flag and removes its prefix. Slack may turn "--"
+// into an em dash so both are accepted.
+func trimFlag(value string) (string, bool) {
+	// Check -- first since - will always find --
+	for _, prefix := range []string{"——", "--", "—", "-"} {
+		if strings.HasPrefix(value, prefix) {
+			return strings.TrimPrefix(value, prefix), true
+		}
+	}
+
+	return value, false
 }
 
 func SanitizeString(s string) string {
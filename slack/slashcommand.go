@@ -0,0 +1,97 @@
+package slack
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// FieldError reports a slash command form field that failed validation
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("slack: invalid %v: %v", e.Field, e.Message)
+}
+
+// ParseSlashCommand reads the form Slack sends for a slash command. POST
+// bodies are what Slack sends, GET query strings are accepted for testing.
+func ParseSlashCommand(r *http.Request) (*SlashCommand, error) {
+	var v url.Values
+
+	switch r.Method {
+	case "POST":
+		err := r.ParseForm()
+		if err != nil {
+			return nil, err
+		}
+		v = r.Form
+	case "GET":
+		v = r.URL.Query()
+	default:
+		return nil, fmt.Errorf("slack: unsupported method %v", r.Method)
+	}
+
+	return NewSlashCommand(v)
+}
+
+// NewSlashCommand creates a SlashCommand from form values and validates them
+func NewSlashCommand(v url.Values) (*SlashCommand, error) {
+	sc := &SlashCommand{
+		Token:          v.Get("token"),
+		TeamId:         v.Get("team_id"),
+		TeamDomain:     v.Get("team_domain"),
+		EnterpriseId:   v.Get("enterprise_id"),
+		EnterpriseName: v.Get("enterprise_name"),
+		ChannelId:      v.Get("channel_id"),
+		ChannelName:    v.Get("channel_name"),
+		UserId:         v.Get("user_id"),
+		UserName:       v.Get("user_name"),
+		Command:        v.Get("command"),
+		Text:           v.Get("text"),
+		APIAppId:       v.Get("api_app_id"),
+		ResponseURL:    v.Get("response_url"),
+		TriggerId:      v.Get("trigger_id"),
+		Hook:           v.Get("hook"),
+	}
+
+	if sc.Command == "" {
+		return nil, &FieldError{"command", "missing"}
+	}
+	if !strings.HasPrefix(sc.Command, "/") {
+		return nil, &FieldError{"command", fmt.Sprintf("%q must start with /", sc.Command)}
+	}
+
+	if s := v.Get("is_enterprise_install"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, &FieldError{"is_enterprise_install", fmt.Sprintf("%q is not a boolean", s)}
+		}
+		sc.IsEnterpriseInstall = b
+	}
+
+	for field, s := range map[string]string{"response_url": sc.ResponseURL, "hook": sc.Hook} {
+		if s != "" && !isCallbackURL(s) {
+			return nil, &FieldError{field, fmt.Sprintf("%q is not an https Slack URL", s)}
+		}
+	}
+
+	return sc, nil
+}
+
+// isCallbackURL reports whether s is an https URL on Slack, Ex.
+// https://hooks.slack.com/commands/..., so a forged request can't have the
+// server post replies elsewhere
+func isCallbackURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return false
+	}
+
+	host := u.Hostname()
+	return host == "slack.com" || strings.HasSuffix(host, ".slack.com")
+}
@@ -0,0 +1,117 @@
+package slack
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSlashCommand(t *testing.T) {
+	v := url.Values{
+		"token":                 {"gIkuvaNzQIHg97ATvDxqgjtO"},
+		"team_id":               {"T0001"},
+		"team_domain":           {"example"},
+		"enterprise_id":         {"E0001"},
+		"enterprise_name":       {"Globular Construct Inc"},
+		"channel_id":            {"C2147483705"},
+		"channel_name":          {"test"},
+		"user_id":               {"U2147483697"},
+		"user_name":             {"Steve"},
+		"command":               {"/weather"},
+		"text":                  {"94070"},
+		"api_app_id":            {"A123456"},
+		"is_enterprise_install": {"false"},
+		"response_url":          {"https://hooks.slack.com/commands/1234/5678"},
+		"trigger_id":            {"13345224609.738474920.8088930838d88f008e0"},
+	}
+
+	expected := &SlashCommand{
+		Token:          "gIkuvaNzQIHg97ATvDxqgjtO",
+		TeamId:         "T0001",
+		TeamDomain:     "example",
+		EnterpriseId:   "E0001",
+		EnterpriseName: "Globular Construct Inc",
+		ChannelId:      "C2147483705",
+		ChannelName:    "test",
+		UserId:         "U2147483697",
+		UserName:       "Steve",
+		Command:        "/weather",
+		Text:           "94070",
+		APIAppId:       "A123456",
+		ResponseURL:    "https://hooks.slack.com/commands/1234/5678",
+		TriggerId:      "13345224609.738474920.8088930838d88f008e0",
+	}
+
+	r := httptest.NewRequest("POST", "/cmd", strings.NewReader(v.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	sc, err := ParseSlashCommand(r)
+	if err != nil {
+		t.Fatal("Test errored. Parse returned", err)
+	}
+	if !reflect.DeepEqual(sc, expected) {
+		t.Errorf("Test errored. SlashCommand should be %+v but is %+v", expected, sc)
+	}
+
+	// GET requests read the query string
+	r = httptest.NewRequest("GET", "/cmd?command=%2Fqotd&text=hello", nil)
+	sc, err = ParseSlashCommand(r)
+	if err != nil || sc.Command != "/qotd" || sc.Text != "hello" {
+		t.Errorf("Test errored. GET should parse /qotd hello but got %+v, %v", sc, err)
+	}
+}
+
+func TestNewSlashCommandInvalid(t *testing.T) {
+	tests := []struct {
+		values url.Values
+		field  string
+	}{
+		{url.Values{"text": {"hello"}}, "command"},
+		{url.Values{"command": {"qotd"}}, "command"},
+		{url.Values{"command": {"/qotd"}, "is_enterprise_install": {"maybe"}}, "is_enterprise_install"},
+		{url.Values{"command": {"/qotd"}, "response_url": {"hooks.slack.com/commands"}}, "response_url"},
+		{url.Values{"command": {"/qotd"}, "hook": {"ftp://example.com"}}, "hook"},
+		{url.Values{"command": {"/qotd"}, "response_url": {"http://hooks.slack.com/commands/1234/5678"}}, "response_url"},
+		{url.Values{"command": {"/qotd"}, "response_url": {"https://example.com/commands/1234/5678"}}, "response_url"},
+		{url.Values{"command": {"/qotd"}, "response_url": {"https://hooks.slack.com.example.com/commands"}}, "response_url"},
+		{url.Values{"command": {"/qotd"}, "response_url": {"https://evilslack.com/commands"}}, "response_url"},
+		{url.Values{"command": {"/qotd"}, "hook": {"https://127.0.0.1/services/T1/B1/x"}}, "hook"},
+	}
+
+	for _, test := range tests {
+		_, err := NewSlashCommand(test.values)
+		fe, ok := err.(*FieldError)
+		if !ok {
+			t.Errorf("Test errored. %v should return a FieldError but returned %v", test.values, err)
+			continue
+		}
+		if fe.Field != test.field {
+			t.Errorf("Test errored. Field should be %v but is %v", test.field, fe.Field)
+		}
+	}
+}
+
+func TestIsCallbackURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected bool
+	}{
+		{"https://hooks.slack.com/commands/T1/1/abc", true},
+		{"https://hooks.slack.com/services/T1/B1/xyz", true},
+		{"https://slack.com/api/chat.postMessage", true},
+		{"https://hooks.slack.com:443/commands/T1/1/abc", true},
+		{"http://hooks.slack.com/commands/T1/1/abc", false},
+		{"https://hooks.slack.com.example.com/commands", false},
+		{"https://user@hooks.slack.com/commands", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"hooks.slack.com/commands", false},
+	}
+
+	for _, test := range tests {
+		if ok := isCallbackURL(test.url); ok != test.expected {
+			t.Errorf("Test errored. isCallbackURL(%q) should be %v but is %v", test.url, test.expected, ok)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Arg describes a positional argument of a Subcommand
+type Arg struct {
+	Name     string
+	Usage    string
+	Required bool
+	Variadic bool // collects the remaining words, must be the last Arg
+}
+
+func (a Arg) String() string {
+	name := a.Name
+	if a.Variadic {
+		name += "..."
+	}
+	if a.Required {
+		return "<" + name + ">"
+	}
+	return "[" + name + "]"
+}
+
+// HandlerFunc runs a Subcommand. args are the words after the subcommand
+// path and sc.Flags holds the parsed flags.
+type HandlerFunc func(ctx context.Context, sc *SlashCommand, args []string) (*CommandPayload, error)
+
+// Subcommand is a node in a tree of slash command verbs. The root is
+// named after the slash command, Ex. "/fg", and children are the words
+// typed after it, Ex. "/fg search". Every level answers "help" with its
+// usage, arguments, subcommands and flags.
+type Subcommand struct {
+	Name        string
+	Usage       string
+	Args        []Arg
+	Flags       func(fs *FlagSet) // declares the flags of this level
+	Handler     HandlerFunc       // nil if the level only groups subcommands
+	Subcommands []*Subcommand
+}
+
+// Request makes a Subcommand tree a Command
+func (s *Subcommand) Request(sc *SlashCommand) (*CommandPayload, error) {
+	return s.RequestContext(context.Background(), sc)
+}
+
+// RequestContext finds the subcommand named by the leading words of the
+// command text and runs its handler with the remaining words
+func (s *Subcommand) RequestContext(ctx context.Context, sc *SlashCommand) (*CommandPayload, error) {
+	args := sc.Args
+	if args == nil {
+		args = []string{}
+		tokens, _ := Tokenize(sc.Text)
+		for _, t := range tokens {
+			args = append(args, t.Value)
+		}
+	}
+
+	path, node, rest := s.resolve(args)
+
+	// "help" at any level returns the usage of that level
+	if len(rest) > 0 && strings.EqualFold(rest[0], "help") {
+		return s.reply(node.help(path, sc.Flags)), nil
+	}
+
+	if node.Handler == nil && len(rest) == 0 {
+		return s.reply(node.help(path, sc.Flags)), nil
+	}
+
+	// a mistyped subcommand, Ex. /fg serch, would otherwise be taken as an
+	// argument of the node's handler
+	var suggestion string
+	if len(rest) > 0 {
+		suggestion = node.suggest(rest[0])
+	}
+	if node.Handler == nil || suggestion != "" {
+		text := fmt.Sprintf("Unknown command `%v` for `%v`.", rest[0], strings.Join(path, " "))
+		if suggestion != "" {
+			text += fmt.Sprintf(" Did you mean `%v`?", suggestion)
+		}
+		return s.reply(text + "\n" + node.help(path, sc.Flags)), nil
+	}
+
+	if err := node.checkArgs(rest); err != "" {
+		if len(rest) > 0 {
+			if suggestion := node.suggest(rest[len(rest)-1]); suggestion != "" {
+				err += fmt.Sprintf(" Did you mean `%v`?", suggestion)
+			}
+		}
+		return s.reply(err + "\n" + node.help(path, sc.Flags)), nil
+	}
+
+	return node.Handler(ctx, sc, rest)
+}
+
+// DefineFlags adds the flags declared along the subcommand path named by
+// text to fs and sets the usage line for that path. The server calls it
+// before separating flags from the text.
+func (s *Subcommand) DefineFlags(fs *FlagSet, text string) {
+	// flag values may be taken for subcommand names here, which only
+	// matters if a value is also the name of a subcommand
+	tokens, _ := Tokenize(text)
+	words, _, _ := separate(nil, tokens)
+
+	path, node, _ := s.resolve(words)
+	for _, n := range s.nodes(path) {
+		if n.Flags != nil {
+			n.Flags(fs)
+		}
+	}
+
+	fs.Usage = node.usageLine(path)
+}
+
+// resolve walks the tree following args and returns the path of names,
+// the node reached and the remaining args
+func (s *Subcommand) resolve(args []string) (path []string, node *Subcommand, rest []string) {
+	node = s
+	path = []string{s.Name}
+
+	for len(args) > 0 {
+		child := node.find(args[0])
+		if child == nil {
+			break
+		}
+		node = child
+		path = append(path, child.Name)
+		args = args[1:]
+	}
+
+	return path, node, args
+}
+
+// nodes returns every node along path starting at the root
+func (s *Subcommand) nodes(path []string) []*Subcommand {
+	nodes := []*Subcommand{s}
+	node := s
+	for _, name := range path[1:] {
+		node = node.find(name)
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func (s *Subcommand) find(name string) *Subcommand {
+	for _, sub := range s.Subcommands {
+		if strings.EqualFold(sub.Name, name) {
+			return sub
+		}
+	}
+	return nil
+}
+
+// checkArgs returns a message if args don't match the declared Args
+func (s *Subcommand) checkArgs(args []string) string {
+	variadic := false
+	for i, a := range s.Args {
+		if a.Required && i >= len(args) {
+			return fmt.Sprintf("Missing argument `%v`.", a.Name)
+		}
+		if a.Variadic {
+			variadic = true
+		}
+	}
+
+	if !variadic && len(args) > len(s.Args) {
+		return fmt.Sprintf("Unexpected argument `%v`.", args[len(s.Args)])
+	}
+
+	return ""
+}
+
+// suggest returns the name of the subcommand closest to a mistyped word
+func (s *Subcommand) suggest(word string) string {
+	var best string
+	bestDistance := 3 // only suggest names within 2 edits
+	if len(word) <= 3 {
+		bestDistance = 2
+	}
+
+	for _, sub := range s.Subcommands {
+		d := levenshtein(strings.ToLower(word), strings.ToLower(sub.Name))
+		if d < bestDistance {
+			best = sub.Name
+			bestDistance = d
+		}
+	}
+
+	return best
+}
+
+func (s *Subcommand) usageLine(path []string) string {
+	line := strings.Join(path, " ")
+	if len(s.Subcommands) > 0 && s.Handler == nil {
+		line += " <command>"
+	}
+	for _, a := range s.Args {
+		line += " " + a.String()
+	}
+	if s.Usage != "" {
+		line += ": " + s.Usage
+	}
+	return line
+}
+
+// help renders the usage of a level in the same style as FlagSet.String
+func (s *Subcommand) help(path []string, fs *FlagSet) string {
+	h := fmt.Sprintf("`%v` \n", s.usageLine(path))
+	h += "```"
+
+	if len(s.Subcommands) > 0 {
+		h += "Commands: \n"
+		for _, sub := range s.Subcommands {
+			h += fmt.Sprintf("• %v: %v \n", sub.Name, sub.Usage)
+		}
+	}
+
+	if len(s.Args) > 0 {
+		h += "Arguments: \n"
+		for _, a := range s.Args {
+			h += fmt.Sprintf("• %v: %v \n", a, a.Usage)
+		}
+	}
+
+	if fs != nil && len(fs.Flags) > 0 {
+		h += "Flags: \n"
+		for _, flag := range fs.Flags {
+			h += fmt.Sprint(flag)
+		}
+	}
+
+	if len(s.Subcommands) > 0 {
+		h += fmt.Sprintf("Type %v <command> help for help with a command \n", strings.Join(path, " "))
+	}
+	h += "```"
+
+	return h
+}
+
+func (s *Subcommand) reply(text string) *CommandPayload {
+	return &CommandPayload{
+		Text:          text,
+		ResponseType:  ResponseEphemeral,
+		SlashResponse: true,
+	}
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,151 @@
+package slack
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestTree(called *string, received *[]string) *Subcommand {
+	handler := func(name string) HandlerFunc {
+		return func(ctx context.Context, sc *SlashCommand, args []string) (*CommandPayload, error) {
+			*called = name
+			*received = args
+			return &CommandPayload{Text: name, SlashResponse: true}, nil
+		}
+	}
+
+	return &Subcommand{
+		Name:    "/fg",
+		Usage:   "FG Trello access",
+		Args:    []Arg{{Name: "board"}, {Name: "list"}},
+		Handler: handler("browse"),
+		Subcommands: []*Subcommand{
+			{
+				Name:    "search",
+				Usage:   "Search cards",
+				Args:    []Arg{{Name: "query", Required: true, Variadic: true}},
+				Flags:   func(fs *FlagSet) { SetIntFlag(fs, "limit", "l", "Maximum number of cards", 10) },
+				Handler: handler("search"),
+			},
+			{
+				Name:  "card",
+				Usage: "Work with cards",
+				Subcommands: []*Subcommand{
+					{Name: "add", Usage: "Add a card", Args: []Arg{{Name: "name", Required: true}}, Handler: handler("card add")},
+					{Name: "move", Usage: "Move a card", Args: []Arg{{Name: "card", Required: true}, {Name: "list", Required: true}}, Handler: handler("card move")},
+					{Name: "label", Usage: "Label a card", Args: []Arg{{Name: "card", Required: true}, {Name: "color"}, {Name: "name", Required: true}}, Handler: handler("card label")},
+				},
+			},
+		},
+	}
+}
+
+func TestSubcommandDispatch(t *testing.T) {
+	var called string
+	var received []string
+	tree := newTestTree(&called, &received)
+
+	tests := []struct {
+		text   string
+		called string
+		args   []string
+		reply  string // part of the reply when no handler should run
+	}{
+		{"", "browse", []string{}, ""},
+		{"design todo", "browse", []string{"design", "todo"}, ""},
+		{"search golang links", "search", []string{"golang", "links"}, ""},
+		{"SEARCH golang", "search", []string{"golang"}, ""},
+		{"card add standup", "card add", []string{"standup"}, ""},
+		{"card move 12 done", "card move", []string{"12", "done"}, ""},
+		{"search", "", nil, "Missing argument `query`"},
+		{"card move 12", "", nil, "Missing argument `list`"},
+		{"card label 12", "", nil, "Missing argument `name`"},
+		{"card label 12 red", "", nil, "Missing argument `name`"},
+		{"card label 12 red urgent", "card label", []string{"12", "red", "urgent"}, ""},
+		{"card", "", nil, "Commands:"},
+		{"card ad standup", "", nil, "Did you mean `add`?"},
+		{"card delete 12", "", nil, "Unknown command `delete`"},
+		{"design todo serch", "", nil, "Did you mean `search`?"},
+		{"serch golang", "", nil, "Did you mean `search`?"},
+		{"crd add standup", "", nil, "Did you mean `card`?"},
+		{"help", "", nil, "/fg [board] [list]: FG Trello access"},
+		{"card move help", "", nil, "/fg card move <card> <list>: Move a card"},
+	}
+
+	for _, test := range tests {
+		called, received = "", nil
+
+		sc := &SlashCommand{Command: "/fg", Text: test.text}
+		cp, err := tree.RequestContext(context.Background(), sc)
+		if err != nil {
+			t.Errorf("Test errored. %q returned %v", test.text, err)
+			continue
+		}
+
+		if called != test.called {
+			t.Errorf("Test errored. %q should call %q but called %q", test.text, test.called, called)
+		}
+		if test.called != "" && !reflect.DeepEqual(received, test.args) {
+			t.Errorf("Test errored. %q args should be %v but are %v", test.text, test.args, received)
+		}
+		if test.reply != "" && !strings.Contains(cp.Text, test.reply) {
+			t.Errorf("Test errored. %q reply should contain %q but is %v", test.text, test.reply, cp.Text)
+		}
+	}
+}
+
+func TestSubcommandDefineFlags(t *testing.T) {
+	var called string
+	var received []string
+	tree := newTestTree(&called, &received)
+
+	fs := &FlagSet{}
+	tree.DefineFlags(fs, "search --limit 5 golang")
+
+	args, flags, _ := fs.Separate("search --limit 5 golang")
+	if _, err := fs.Parse(flags); err != nil {
+		t.Fatal("Test errored. Parse returned", err)
+	}
+
+	sc := &SlashCommand{Command: "/fg", Args: args, Flags: fs}
+	tree.RequestContext(context.Background(), sc)
+
+	if called != "search" || !reflect.DeepEqual(received, []string{"golang"}) {
+		t.Errorf("Test errored. search should be called with [golang] but %q was called with %v", called, received)
+	}
+	if fs.Int("limit") != 5 {
+		t.Errorf("Test errored. limit should be 5 but is %v", fs.Int("limit"))
+	}
+	if fs.Usage != "/fg search <query...>: Search cards" {
+		t.Errorf("Test errored. Usage should be for search but is %v", fs.Usage)
+	}
+
+	// flags of other subcommands are not defined
+	fs = &FlagSet{}
+	tree.DefineFlags(fs, "card add standup")
+	if fs.Lookup("limit") != nil {
+		t.Error("Test errored. limit should only be defined for search")
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"add", "add", 0},
+		{"ad", "add", 1},
+		{"serch", "search", 1},
+		{"mvoe", "move", 2},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, test := range tests {
+		if d := levenshtein(test.a, test.b); d != test.distance {
+			t.Errorf("Test errored. Distance from %v to %v should be %v but is %v", test.a, test.b, test.distance, d)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is a word of slash command text
+type Token struct {
+	Value  string
+	Pos    int  // byte offset of the token in the text
+	Quoted bool // the token started with a quote or escape so it is never a flag
+}
+
+// SyntaxError reports text that could not be tokenized
+type SyntaxError struct {
+	Pos int // byte offset of the problem in the text
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v at position %v", e.Msg, e.Pos)
+}
+
+// Context returns text with a caret under the position of the error
+func (e *SyntaxError) Context(text string) string {
+	pos := e.Pos
+	if pos > len(text) {
+		pos = len(text)
+	}
+
+	return text + "\n" + strings.Repeat(" ", utf8.RuneCountInString(text[:pos])) + "^"
+}
+
+// closing quotes for each opening quote. Slack clients often replace
+// straight quotes with smart quotes so either closes.
+var quotes = map[rune][]rune{
+	'"':  {'"', '”'},
+	'“':  {'”', '"'},
+	'\'': {'\'', '’'},
+	'‘':  {'’', '\''},
+}
+
+// Tokenize splits slash command text into words like a shell. Words are
+// separated by spaces unless quoted with single or double quotes, and a
+// backslash escapes the next character outside of single quotes. Single
+// quotes only open a quote at the start of a word so apostrophes like
+// "don't" are kept.
+// Ex. `add "Design Team" 'To Do'` returns ["add", "Design Team", "To Do"]
+func Tokenize(s string) ([]Token, error) {
+	var tokens []Token
+	var b strings.Builder
+	var tok *Token
+
+	start := func(pos int, quoted bool) {
+		if tok == nil {
+			tok = &Token{Pos: pos, Quoted: quoted}
+		}
+	}
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case unicode.IsSpace(r):
+			if tok != nil {
+				tok.Value = b.String()
+				tokens = append(tokens, *tok)
+				tok = nil
+				b.Reset()
+			}
+			i += size
+
+		case r == '\\':
+			if i+size >= len(s) {
+				return nil, &SyntaxError{i, "trailing backslash"}
+			}
+			start(i, true)
+			next, nextSize := utf8.DecodeRuneInString(s[i+size:])
+			b.WriteRune(next)
+			i += size + nextSize
+
+		case quotes[r] != nil && (r == '"' || r == '“' || tok == nil):
+			start(i, true)
+			end, err := readQuoted(s, i, r, &b)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+		default:
+			start(i, false)
+			b.WriteRune(r)
+			i += size
+		}
+	}
+
+	if tok != nil {
+		tok.Value = b.String()
+		tokens = append(tokens, *tok)
+	}
+
+	return tokens, nil
+}
+
+// readQuoted writes the quoted text starting at pos to b and returns the
+// offset after the closing quote
+func readQuoted(s string, pos int, open rune, b *strings.Builder) (int, error) {
+	double := open == '"' || open == '“'
+	i := pos + utf8.RuneLen(open)
+
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+
+		for _, c := range quotes[open] {
+			if r == c {
+				return i + size, nil
+			}
+		}
+
+		// only quotes and backslashes are escaped inside double quotes
+		if double && r == '\\' && i+size < len(s) {
+			next, nextSize := utf8.DecodeRuneInString(s[i+size:])
+			if next == '\\' || quotes[next] != nil {
+				b.WriteRune(next)
+				i += size + nextSize
+				continue
+			}
+		}
+
+		b.WriteRune(r)
+		i += size
+	}
+
+	return 0, &SyntaxError{pos, "unterminated quote"}
+}
@@ -0,0 +1,107 @@
+package slack
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text   string
+		tokens []Token
+	}{
+		{"golang links", []Token{{"golang", 0, false}, {"links", 7, false}}},
+		{`  add  "Design Team"`, []Token{{"add", 2, false}, {"Design Team", 7, true}}},
+		{`'To Do' list`, []Token{{"To Do", 0, true}, {"list", 8, false}}},
+		{"“Design Team” ‘To Do’", []Token{{"Design Team", 0, true}, {"To Do", 18, true}}},
+		{"“mixed quotes\"", []Token{{"mixed quotes", 0, true}}},
+		{"don't stop", []Token{{"don't", 0, false}, {"stop", 6, false}}},
+		{"don’t stop", []Token{{"don’t", 0, false}, {"stop", 8, false}}},
+		{`name="Design Team"`, []Token{{"name=Design Team", 0, false}}},
+		{`\-5 "say \"hi\"" a\ b`, []Token{{"-5", 0, true}, {`say "hi"`, 4, true}, {"a b", 17, false}}},
+		{`'C:\path'`, []Token{{`C:\path`, 0, true}}},
+		{`""`, []Token{{"", 0, true}}},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		tokens, err := Tokenize(test.text)
+		if err != nil {
+			t.Errorf("Test errored. %q returned %v", test.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(tokens, test.tokens) {
+			t.Errorf("Test errored. %q should be %+v but is %+v", test.text, test.tokens, tokens)
+		}
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	tests := []struct {
+		text    string
+		pos     int
+		context string
+	}{
+		{`search "design`, 7, "search \"design\n       ^"},
+		{`add 'To Do`, 4, "add 'To Do\n    ^"},
+		{`trailing \`, 9, "trailing \\\n         ^"},
+	}
+
+	for _, test := range tests {
+		_, err := Tokenize(test.text)
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("Test errored. %q should return a SyntaxError but returned %v", test.text, err)
+			continue
+		}
+		if serr.Pos != test.pos {
+			t.Errorf("Test errored. %q error should be at %v but is at %v", test.text, test.pos, serr.Pos)
+		}
+		if c := serr.Context(test.text); c != test.context {
+			t.Errorf("Test errored. Context should be %q but is %q", test.context, c)
+		}
+	}
+}
+
+func TestSeparateQuoted(t *testing.T) {
+	fs := &FlagSet{}
+	SetFlag(fs, "channel", "c", "channel flag usage", func() {})
+	SetStringFlag(fs, "offset", "o", "Offset to apply", "")
+
+	tests := []struct {
+		text  string
+		args  []string
+		flags []string
+	}{
+		{`"Design Team" "To Do" -c`, []string{"Design Team", "To Do"}, []string{"c"}},
+		{`--offset -5 links`, []string{"links"}, []string{"offset=-5"}},
+		{`--offset="-5 days" links`, []string{"links"}, []string{"offset=-5 days"}},
+		{`"-c" -- -p --offset 5`, []string{"-c", "-p", "--offset", "5"}, nil},
+		{`links — -c`, []string{"links", "—"}, []string{"c"}}, // only "--" ends the flags
+		{`--offset -c`, nil, nil},
+		{`--offset --limit 5`, nil, nil},
+		{`--offset "-c" links`, []string{"links"}, []string{"offset=-c"}},
+		{`- links`, []string{"-", "links"}, nil},
+	}
+
+	for _, test := range tests {
+		args, flags, err := fs.Separate(test.text)
+		if test.args == nil && test.flags == nil {
+			if err == nil || !strings.HasPrefix(err.Error(), "flag needs an argument") {
+				t.Errorf("Test errored. %q should need an argument but returned %v", test.text, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test errored. %q returned %v", test.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("Test errored. %q args should be %q but are %q", test.text, test.args, args)
+		}
+		if !reflect.DeepEqual(flags, test.flags) {
+			t.Errorf("Test errored. %q flags should be %q but are %q", test.text, test.flags, flags)
+		}
+	}
+}
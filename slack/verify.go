@@ -0,0 +1,144 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Headers Slack uses to sign requests - https://api.slack.com/authentication/verifying-requests-from-slack
+const (
+	SignatureHeader = "X-Slack-Signature"
+	TimestampHeader = "X-Slack-Request-Timestamp"
+)
+
+// MaxRequestAge is how far a request timestamp may drift from the server
+// clock before it is rejected as a possible replay
+const MaxRequestAge = 5 * time.Minute
+
+var (
+	ErrMissingSignature = errors.New("slack: missing request signature")
+	ErrInvalidSignature = errors.New("slack: invalid request signature")
+	ErrStaleTimestamp   = errors.New("slack: request timestamp outside of allowed window")
+)
+
+// VerifySignature checks the X-Slack-Signature header against the
+// HMAC-SHA256 of "v0:timestamp:body" keyed with the signing secret
+func VerifySignature(secret string, h http.Header, body []byte, now time.Time) error {
+	signature := h.Get(SignatureHeader)
+	timestamp := h.Get(TimestampHeader)
+	if signature == "" || timestamp == "" {
+		return ErrMissingSignature
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	age := now.Sub(time.Unix(ts, 0))
+	if age > MaxRequestAge || age < -MaxRequestAge {
+		return ErrStaleTimestamp
+	}
+
+	expected := []byte(Sign(secret, timestamp, body))
+	if !hmac.Equal(expected, []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// Sign returns the v0 signature Slack would send for a timestamp and body
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type verifiedKey struct{}
+
+// IsVerified reports whether VerifyRequests validated the request signature
+func IsVerified(r *http.Request) bool {
+	v, _ := r.Context().Value(verifiedKey{}).(bool)
+	return v
+}
+
+// VerifyRequests is middleware that validates signed requests before
+// passing them to next. Requests with a bad or stale signature are
+// rejected, and so are unsigned requests unless they're slash commands of
+// a command with LegacyToken set. Those, and every request when secret is
+// empty, are passed through unverified to be checked against the legacy
+// verification token.
+func VerifyRequests(secret string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if secret == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		if r.Header.Get(SignatureHeader) == "" {
+			if !allowsLegacyToken(body) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		err = VerifySignature(secret, r.Header, body, time.Now())
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// restore the body so handlers can parse the form
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		ctx := context.WithValue(r.Context(), verifiedKey{}, true)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// allowsLegacyToken reports whether body is a slash command of a command
+// that accepts unsigned requests with its legacy token
+func allowsLegacyToken(body []byte) bool {
+	v, err := url.ParseQuery(string(body))
+	if err != nil {
+		return false
+	}
+
+	ci, ok := Lookup(v.Get("command"))
+	return ok && ci.LegacyToken
+}
+
+// VerifyToken checks a legacy verification token against the
+// verification_token setting of the command. It always fails when the
+// command has no token configured.
+func (ci *CommandInfo) VerifyToken(settings Settings, token string) bool {
+	expected := settings.Get("verification_token")
+	if ci.TokenEnv == "" || expected == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
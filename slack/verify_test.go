@@ -0,0 +1,120 @@
+package slack
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Now()
+	body := []byte("token=xyz&team_id=T1&command=%2Fqotd&text=")
+	ts := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		signature string
+		timestamp string
+		err       error
+	}{
+		{Sign(testSecret, ts, body), ts, nil},
+		{Sign("wrong secret", ts, body), ts, ErrInvalidSignature},
+		{Sign(testSecret, stale, body), stale, ErrStaleTimestamp},
+		{Sign(testSecret, ts, body), "", ErrMissingSignature},
+		{"", ts, ErrMissingSignature},
+		{Sign(testSecret, ts, body), "notanumber", ErrInvalidSignature},
+	}
+
+	for _, test := range tests {
+		h := http.Header{}
+		h.Set(SignatureHeader, test.signature)
+		h.Set(TimestampHeader, test.timestamp)
+
+		err := VerifySignature(testSecret, h, body, now)
+		if err != test.err {
+			t.Errorf("Test errored. Error should be %v but is %v", test.err, err)
+		}
+	}
+}
+
+func TestVerifyRequests(t *testing.T) {
+	isolate(t)
+	Register(CommandInfo{Names: []string{"/legacytest"}, TokenEnv: "SLACK_KEY_LEGACYTEST", LegacyToken: true, New: newTestCommand})
+	Register(CommandInfo{Names: []string{"/signedtest"}, TokenEnv: "SLACK_KEY_SIGNEDTEST", New: newTestCommand})
+
+	body := "command=%2Fsignedtest&text=hello"
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	tests := []struct {
+		name      string
+		secret    string
+		body      string
+		signature string
+		status    int
+		verified  bool
+	}{
+		{"signed", testSecret, body, Sign(testSecret, ts, []byte(body)), http.StatusOK, true},
+		{"bad signature", testSecret, body, Sign("wrong secret", ts, []byte(body)), http.StatusUnauthorized, false},
+		// leaving the signature off doesn't downgrade to the legacy token
+		{"unsigned", testSecret, body, "", http.StatusUnauthorized, false},
+		{"unsigned interaction", testSecret, "payload=%7B%7D", "", http.StatusUnauthorized, false},
+		{"unsigned legacy command", testSecret, "command=%2Flegacytest&text=hello", "", http.StatusOK, false},
+		{"no secret", "", body, "", http.StatusOK, false},
+	}
+
+	for _, test := range tests {
+		var verified bool
+		var received string
+		h := VerifyRequests(test.secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			verified = IsVerified(r)
+			b, _ := ioutil.ReadAll(r.Body)
+			received = string(b)
+		}))
+
+		r := httptest.NewRequest("POST", "/cmd", strings.NewReader(test.body))
+		if test.signature != "" {
+			r.Header.Set(SignatureHeader, test.signature)
+			r.Header.Set(TimestampHeader, ts)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Errorf("Test errored. %v status should be %v but is %v", test.name, test.status, w.Code)
+		}
+		if verified != test.verified {
+			t.Errorf("Test errored. %v verified should be %v but is %v", test.name, test.verified, verified)
+		}
+		if test.status == http.StatusOK && received != test.body {
+			t.Errorf("Test errored. %v body should be %v but is %v", test.name, test.body, received)
+		}
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	tests := []struct {
+		tokenEnv string
+		settings Settings
+		token    string
+		expected bool
+	}{
+		{"SLACK_KEY_FG", Settings{"verification_token": "abc"}, "abc", true},
+		{"SLACK_KEY_FG", Settings{"verification_token": "abc"}, "xyz", false},
+		{"SLACK_KEY_FG", Settings{"verification_token": "abc"}, "", false},
+		{"SLACK_KEY_FG", Settings{}, "", false},                   // no token configured
+		{"", Settings{"verification_token": "abc"}, "abc", false}, // command without legacy tokens
+	}
+
+	for _, test := range tests {
+		ci := &CommandInfo{Names: []string{"/fg"}, TokenEnv: test.tokenEnv}
+		if ok := ci.VerifyToken(test.settings, test.token); ok != test.expected {
+			t.Errorf("Test errored. VerifyToken(%v, %q) with TokenEnv %q should be %v but is %v", test.settings, test.token, test.tokenEnv, test.expected, ok)
+		}
+	}
+}
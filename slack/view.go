@@ -0,0 +1,92 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// View is a modal or app home surface
+// https://api.slack.com/reference/surfaces/views
+type View struct {
+	Id              string      `json:"id,omitempty"`
+	Type            string      `json:"type"`
+	Title           *TextObject `json:"title,omitempty"`
+	Submit          *TextObject `json:"submit,omitempty"`
+	Close           *TextObject `json:"close,omitempty"`
+	Blocks          []Block     `json:"blocks"`
+	CallbackId      string      `json:"callback_id,omitempty"`
+	PrivateMetadata string      `json:"private_metadata,omitempty"`
+	ExternalId      string      `json:"external_id,omitempty"`
+	ClearOnClose    bool        `json:"clear_on_close,omitempty"`
+	NotifyOnClose   bool        `json:"notify_on_close,omitempty"`
+
+	// set by Slack on views it sends
+	Hash  string     `json:"hash,omitempty"`
+	State *ViewState `json:"state,omitempty"`
+}
+
+// NewModal returns a modal view with plain text title and submit button
+func NewModal(callbackID string, title string, submit string, blocks []Block) *View {
+	v := &View{
+		Type:       "modal",
+		Title:      NewPlainText(title),
+		Close:      NewPlainText("Cancel"),
+		Blocks:     blocks,
+		CallbackId: callbackID,
+	}
+	if submit != "" {
+		v.Submit = NewPlainText(submit)
+	}
+
+	return v
+}
+
+// UnmarshalJSON decodes a view sent by Slack. Blocks are not decoded, the
+// values users entered are in State.
+func (v *View) UnmarshalJSON(b []byte) error {
+	type alias View
+	aux := struct {
+		*alias
+		Blocks json.RawMessage `json:"blocks"`
+	}{alias: (*alias)(v)}
+
+	return json.Unmarshal(b, &aux)
+}
+
+// ViewState holds the values of the input elements in a view by block_id
+// and action_id
+type ViewState struct {
+	Values map[string]map[string]Action `json:"values"`
+}
+
+// Value returns the value entered in an element or "" if it's empty
+func (s *ViewState) Value(blockID string, actionID string) string {
+	if s == nil {
+		return ""
+	}
+	return s.Values[blockID][actionID].SelectedValue()
+}
+
+// ValidationErrors maps the block_id of an input to the message shown
+// under it. A view_submission handler returns it to keep the modal open.
+type ValidationErrors map[string]string
+
+func (e ValidationErrors) Error() string {
+	var msgs []string
+	for blockID, msg := range e {
+		msgs = append(msgs, fmt.Sprintf("%v: %v", blockID, msg))
+	}
+	sort.Strings(msgs)
+
+	return "invalid input: " + strings.Join(msgs, "; ")
+}
+
+// submissionResponse answers a view_submission
+// https://api.slack.com/surfaces/modals#displaying_errors
+type submissionResponse struct {
+	ResponseAction string            `json:"response_action"`
+	Errors         map[string]string `json:"errors,omitempty"`
+	View           *View             `json:"view,omitempty"`
+}
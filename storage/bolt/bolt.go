@@ -0,0 +1,182 @@
+// Package bolt is a storage.Store kept in a bbolt file
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	bbolt "go.etcd.io/bbolt"
+
+	"github.com/jesselucas/slackcmd/storage"
+)
+
+var (
+	valuesBucket = []byte("values")
+	listsBucket  = []byte("lists")
+)
+
+// Store is a storage.Store in a bolt database file
+type Store struct {
+	db *bbolt.DB
+}
+
+// Open opens or creates the database at path
+func Open(path string) (*Store, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(valuesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(listsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db}, nil
+}
+
+// values are stored after the 8 byte unix nanosecond expiry, 0 for never
+func encode(value []byte, expires time.Time) []byte {
+	b := make([]byte, 8+len(value))
+	if !expires.IsZero() {
+		binary.BigEndian.PutUint64(b, uint64(expires.UnixNano()))
+	}
+	copy(b[8:], value)
+	return b
+}
+
+// decode returns a copy of the value or false if it is missing or expired
+func decode(b []byte, now time.Time) ([]byte, bool) {
+	if len(b) < 8 {
+		return nil, false
+	}
+	if expires := binary.BigEndian.Uint64(b); expires != 0 && now.UnixNano() >= int64(expires) {
+		return nil, false
+	}
+	return append([]byte{}, b[8:]...), true
+}
+
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	var ok bool
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		value, ok = decode(tx.Bucket(valuesBucket).Get([]byte(key)), time.Now())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(valuesBucket).Put([]byte(key), encode(value, storage.Expires(time.Now(), ttl)))
+	})
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		err := tx.Bucket(valuesBucket).Delete([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(listsBucket).DeleteBucket([]byte(key))
+		if err == bbolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// Update runs fn inside a bolt write transaction, which bolt serializes
+func (s *Store) Update(ctx context.Context, key string, ttl time.Duration, fn func([]byte) ([]byte, error)) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(valuesBucket)
+		old, _ := decode(b.Get([]byte(key)), time.Now())
+
+		value, err := fn(old)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), encode(value, storage.Expires(time.Now(), ttl)))
+	})
+}
+
+// Push stores each list as a bucket of values keyed by sequence number
+func (s *Store) Push(ctx context.Context, key string, value []byte, max int) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		l, err := tx.Bucket(listsBucket).CreateBucketIfNotExists([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		seq, err := l.NextSequence()
+		if err != nil {
+			return err
+		}
+		id := make([]byte, 8)
+		binary.BigEndian.PutUint64(id, seq)
+		err = l.Put(id, value)
+		if err != nil {
+			return err
+		}
+
+		if max <= 0 {
+			return nil
+		}
+
+		// drop the oldest values past max
+		var ids [][]byte
+		l.ForEach(func(k, v []byte) error {
+			ids = append(ids, k)
+			return nil
+		})
+		for len(ids) > max {
+			err = l.Delete(ids[0])
+			if err != nil {
+				return err
+			}
+			ids = ids[1:]
+		}
+		return nil
+	})
+}
+
+func (s *Store) List(ctx context.Context, key string, limit int) ([][]byte, error) {
+	var values [][]byte
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		l := tx.Bucket(listsBucket).Bucket([]byte(key))
+		if l == nil {
+			return nil
+		}
+
+		// walk back from the newest value
+		c := l.Cursor()
+		for k, v := c.Last(); k != nil && (limit <= 0 || len(values) < limit); k, v = c.Prev() {
+			values = append(values, append([]byte{}, v...))
+		}
+		return nil
+	})
+
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+	return values, err
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
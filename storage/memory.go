@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a Store held in memory. Everything is lost when the server
+// restarts.
+type Memory struct {
+	mu     sync.Mutex
+	values map[string]entry
+	lists  map[string][][]byte
+}
+
+type entry struct {
+	value   []byte
+	expires time.Time
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		values: make(map[string]entry),
+		lists:  make(map[string][][]byte),
+	}
+}
+
+// get returns the value of key, removing it if it expired. m.mu must be held.
+func (m *Memory) get(key string) ([]byte, bool) {
+	e, ok := m.values[key]
+	if !ok {
+		return nil, false
+	}
+	if e.expired(time.Now()) {
+		delete(m.values, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	v, ok := m.get(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyBytes(v), nil
+}
+
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.values[key] = entry{copyBytes(value), Expires(time.Now(), ttl)}
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.values, key)
+	delete(m.lists, key)
+	return nil
+}
+
+func (m *Memory) Update(ctx context.Context, key string, ttl time.Duration, fn func([]byte) ([]byte, error)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, _ := m.get(key)
+	v, err := fn(copyBytes(old))
+	if err != nil {
+		return err
+	}
+
+	m.values[key] = entry{copyBytes(v), Expires(time.Now(), ttl)}
+	return nil
+}
+
+func (m *Memory) Push(ctx context.Context, key string, value []byte, max int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l := append(m.lists[key], copyBytes(value))
+	if max > 0 && len(l) > max {
+		l = append([][]byte(nil), l[len(l)-max:]...)
+	}
+	m.lists[key] = l
+	return nil
+}
+
+func (m *Memory) List(ctx context.Context, key string, limit int) ([][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l := m.lists[key]
+	if limit > 0 && len(l) > limit {
+		l = l[len(l)-limit:]
+	}
+
+	values := make([][]byte, len(l))
+	for i, v := range l {
+		values[i] = copyBytes(v)
+	}
+	return values, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
@@ -0,0 +1,198 @@
+// Package sqlite is a storage.Store kept in a SQLite database
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/jesselucas/slackcmd/storage"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS kv (
+	key     TEXT PRIMARY KEY,
+	value   BLOB NOT NULL,
+	expires INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS lists (
+	id    INTEGER PRIMARY KEY AUTOINCREMENT,
+	key   TEXT NOT NULL,
+	value BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS lists_key ON lists (key, id);
+`
+
+// Store is a storage.Store in a SQLite database
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens or creates the database at path
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows one writer at a time. Sharing one connection also
+	// makes Update's read and write atomic.
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db}, nil
+}
+
+// expiresAt returns the expiry stored for ttl, 0 for never
+func expiresAt(ttl time.Duration) int64 {
+	expires := storage.Expires(time.Now(), ttl)
+	if expires.IsZero() {
+		return 0
+	}
+	return expires.UnixNano()
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func get(ctx context.Context, q querier, key string) ([]byte, error) {
+	var value []byte
+	err := q.QueryRowContext(ctx,
+		"SELECT value FROM kv WHERE key = ? AND (expires = 0 OR expires > ?)",
+		key, time.Now().UnixNano(),
+	).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrNotFound
+	}
+	return value, err
+}
+
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	return get(ctx, s.db, key)
+}
+
+const upsert = "INSERT INTO kv (key, value, expires) VALUES (?, ?, ?) " +
+	"ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires = excluded.expires"
+
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if value == nil {
+		value = []byte{}
+	}
+	_, err := s.db.ExecContext(ctx, upsert, key, value, expiresAt(ttl))
+	return err
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM kv WHERE key = ?", key)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM lists WHERE key = ?", key)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) Update(ctx context.Context, key string, ttl time.Duration, fn func([]byte) ([]byte, error)) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := get(ctx, tx, key)
+	if err != nil && err != storage.ErrNotFound {
+		return err
+	}
+
+	value, err := fn(old)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+
+	_, err = tx.ExecContext(ctx, upsert, key, value, expiresAt(ttl))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) Push(ctx context.Context, key string, value []byte, max int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if value == nil {
+		value = []byte{}
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO lists (key, value) VALUES (?, ?)", key, value)
+	if err != nil {
+		return err
+	}
+
+	if max > 0 {
+		_, err = tx.ExecContext(ctx,
+			"DELETE FROM lists WHERE key = ? AND id NOT IN (SELECT id FROM lists WHERE key = ? ORDER BY id DESC LIMIT ?)",
+			key, key, max,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) List(ctx context.Context, key string, limit int) ([][]byte, error) {
+	// LIMIT -1 is no limit in SQLite
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT value FROM (SELECT id, value FROM lists WHERE key = ? ORDER BY id DESC LIMIT ?) ORDER BY id",
+		key, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values [][]byte
+	for rows.Next() {
+		var v []byte
+		err = rows.Scan(&v)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	return values, rows.Err()
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,18 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jesselucas/slackcmd/storage/storagetest"
+)
+
+func TestStore(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "slackcmd.sqlite"))
+	if err != nil {
+		t.Fatal("Test errored. Open returned", err)
+	}
+	defer s.Close()
+
+	storagetest.Run(t, s)
+}
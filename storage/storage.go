@@ -0,0 +1,105 @@
+// Package storage lets commands remember data between requests. Values
+// are opaque bytes stored by key, optionally expiring, plus append only
+// lists. Backends are in memory, bolt and sqlite.
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by Get when a key isn't set or has expired
+var ErrNotFound = errors.New("storage: not found")
+
+// Store is implemented by every backend. Implementations are safe for
+// concurrent use.
+type Store interface {
+	// Get returns the value of key or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key. It expires after ttl, or never when ttl
+	// is 0.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key and the list named key
+	Delete(ctx context.Context, key string) error
+	// Update atomically replaces the value of key with the result of fn.
+	// fn is passed nil when key isn't set. Nothing is written if fn
+	// returns an error.
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(value []byte) ([]byte, error)) error
+
+	// Push appends value to the list named key. When max is above 0 the
+	// list is trimmed to its last max values.
+	Push(ctx context.Context, key string, value []byte, max int) error
+	// List returns the last limit values of the list named key, oldest
+	// first, or every value when limit is 0
+	List(ctx context.Context, key string, limit int) ([][]byte, error)
+
+	Close() error
+}
+
+// Namespace returns a Store that prefixes every key with "prefix:" so
+// commands sharing a backend don't collide. Closing it doesn't close s.
+func Namespace(s Store, prefix string) Store {
+	return &namespace{s, prefix + ":"}
+}
+
+type namespace struct {
+	s      Store
+	prefix string
+}
+
+func (n *namespace) Get(ctx context.Context, key string) ([]byte, error) {
+	return n.s.Get(ctx, n.prefix+key)
+}
+
+func (n *namespace) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return n.s.Set(ctx, n.prefix+key, value, ttl)
+}
+
+func (n *namespace) Delete(ctx context.Context, key string) error {
+	return n.s.Delete(ctx, n.prefix+key)
+}
+
+func (n *namespace) Update(ctx context.Context, key string, ttl time.Duration, fn func([]byte) ([]byte, error)) error {
+	return n.s.Update(ctx, n.prefix+key, ttl, fn)
+}
+
+func (n *namespace) Push(ctx context.Context, key string, value []byte, max int) error {
+	return n.s.Push(ctx, n.prefix+key, value, max)
+}
+
+func (n *namespace) List(ctx context.Context, key string, limit int) ([][]byte, error) {
+	return n.s.List(ctx, n.prefix+key, limit)
+}
+
+func (n *namespace) Close() error {
+	return nil
+}
+
+// GetJSON decodes the JSON value of key into v
+func GetJSON(ctx context.Context, s Store, key string, v interface{}) error {
+	b, err := s.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// SetJSON stores v encoded as JSON under key
+func SetJSON(ctx context.Context, s Store, key string, v interface{}, ttl time.Duration) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Set(ctx, key, b, ttl)
+}
+
+// Expires returns when a value set now with ttl expires, the zero time for
+// values that never expire. Backends use it to store expiry times.
+func Expires(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
@@ -0,0 +1,12 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/jesselucas/slackcmd/storage"
+	"github.com/jesselucas/slackcmd/storage/storagetest"
+)
+
+func TestMemory(t *testing.T) {
+	storagetest.Run(t, storage.NewMemory())
+}
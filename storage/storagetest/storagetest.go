@@ -0,0 +1,103 @@
+// Package storagetest checks that a storage.Store backend behaves like the
+// others
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jesselucas/slackcmd/storage"
+)
+
+// Run tests the backend s. s must be empty.
+func Run(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	// get and set
+	if _, err := s.Get(ctx, "missing"); err != storage.ErrNotFound {
+		t.Errorf("Test errored. Get of a missing key should return ErrNotFound but returned %v", err)
+	}
+	if err := s.Set(ctx, "color", []byte("green"), 0); err != nil {
+		t.Fatal("Test errored. Set returned", err)
+	}
+	if v, err := s.Get(ctx, "color"); string(v) != "green" || err != nil {
+		t.Errorf("Test errored. Value should be green but is %q (%v)", v, err)
+	}
+
+	// ttl
+	s.Set(ctx, "short", []byte("gone"), 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if _, err := s.Get(ctx, "short"); err != storage.ErrNotFound {
+		t.Errorf("Test errored. Expired key should return ErrNotFound but returned %v", err)
+	}
+
+	// namespaces don't see each other's keys
+	a := storage.Namespace(s, "a")
+	b := storage.Namespace(s, "b")
+	a.Set(ctx, "color", []byte("red"), 0)
+	if _, err := b.Get(ctx, "color"); err != storage.ErrNotFound {
+		t.Errorf("Test errored. Namespace b should not see a's key but returned %v", err)
+	}
+	if v, _ := s.Get(ctx, "color"); string(v) != "green" {
+		t.Errorf("Test errored. Namespaced Set should not change the unprefixed key but it is %q", v)
+	}
+
+	// atomic updates
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.Update(ctx, "counter", 0, func(v []byte) ([]byte, error) {
+				var n int
+				fmt.Sscan(string(v), &n)
+				return []byte(fmt.Sprint(n + 1)), nil
+			})
+			if err != nil {
+				t.Error("Test errored. Update returned", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := s.Get(ctx, "counter"); string(v) != "20" {
+		t.Errorf("Test errored. Counter should be 20 but is %q", v)
+	}
+
+	failed := errors.New("failed")
+	if err := s.Update(ctx, "counter", 0, func(v []byte) ([]byte, error) { return []byte("0"), failed }); err != failed {
+		t.Errorf("Test errored. Update should return the error of fn but returned %v", err)
+	}
+	if v, _ := s.Get(ctx, "counter"); string(v) != "20" {
+		t.Errorf("Test errored. Failed Update should not write but counter is %q", v)
+	}
+
+	// lists
+	for _, q := range []string{"q1", "q2", "q3", "q4"} {
+		if err := s.Push(ctx, "history", []byte(q), 3); err != nil {
+			t.Fatal("Test errored. Push returned", err)
+		}
+	}
+	tests := []struct {
+		limit    int
+		expected string
+	}{
+		{0, "[q2 q3 q4]"},
+		{2, "[q3 q4]"},
+		{10, "[q2 q3 q4]"},
+	}
+	for _, test := range tests {
+		values, err := s.List(ctx, "history", test.limit)
+		if got := fmt.Sprintf("%s", values); got != test.expected || err != nil {
+			t.Errorf("Test errored. List(%v) should be %v but is %v (%v)", test.limit, test.expected, got, err)
+		}
+	}
+
+	s.Delete(ctx, "history")
+	if values, _ := s.List(ctx, "history", 0); len(values) != 0 {
+		t.Errorf("Test errored. Deleted list should be empty but is %s", values)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jesselucas/slackcmd/config"
+	"github.com/jesselucas/slackcmd/ratelimit"
+	"github.com/jesselucas/slackcmd/slack"
+)
+
+// limit is one of the rate limits a slash command is checked against
+type limit struct {
+	name    string // user, channel, team or command
+	limiter *ratelimit.Limiter
+	key     func(t slack.Trigger) string
+}
+
+// limits are checked before every command runs
+var limits []limit
+
+// newLimits returns the limits of the rates that are set. Every key has the
+// command name so a busy command doesn't hold up the others.
+func newLimits(c config.RateLimit) []limit {
+	var ls []limit
+	for _, l := range []struct {
+		name string
+		rate config.Rate
+		key  func(t slack.Trigger) string
+	}{
+		{"user", c.User, func(t slack.Trigger) string { return t.TeamId + "/" + t.UserId }},
+		{"channel", c.Channel, func(t slack.Trigger) string { return t.TeamId + "/" + t.ChannelId }},
+		{"team", c.Team, func(t slack.Trigger) string { return t.TeamId }},
+		{"command", c.Command, func(t slack.Trigger) string { return "" }},
+	} {
+		if l.rate.Requests <= 0 {
+			continue
+		}
+		ls = append(ls, limit{
+			name:    l.name,
+			limiter: ratelimit.New(ratelimit.Per(l.rate.Requests, l.rate.Per.Duration, l.rate.Burst)),
+			key:     l.key,
+		})
+	}
+	return ls
+}
+
+// throttle takes a token from every limit for the command run by t. When any is
+// out it returns its name and how long until the command can run again,
+// and the tokens taken from the other limits are given back so a throttled
+// user doesn't use up the channel's or team's.
+func throttle(command string, t slack.Trigger) (string, time.Duration) {
+	var hit string
+	var wait time.Duration
+	var taken []limit
+	for _, l := range limits {
+		ok, d := l.limiter.Allow(command + " " + l.key(t))
+		if ok {
+			taken = append(taken, l)
+		} else if d > wait {
+			hit, wait = l.name, d
+		}
+	}
+
+	if hit != "" {
+		for _, l := range taken {
+			l.limiter.Refund(command + " " + l.key(t))
+		}
+	}
+	return hit, wait
+}
+
+// throttled is the reply of a command that hit a rate limit
+func throttled(wait time.Duration) *slack.CommandPayload {
+	return &slack.CommandPayload{
+		Text:         slowDown(wait),
+		ResponseType: slack.ResponseEphemeral,
+	}
+}
+
+// errThrottled is the error of an interaction or event handler that hit a
+// rate limit
+func errThrottled(wait time.Duration) error {
+	return slack.Errorf(slack.BadInput, "%v", slowDown(wait))
+}
+
+func slowDown(wait time.Duration) string {
+	return fmt.Sprintf("Slow down, try again in %vs.", math.Ceil(wait.Seconds()))
+}